@@ -3,11 +3,13 @@
 package app
 
 import (
+	"context"
 	"log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/parkerduckworth/lonchera/app/config"
 	"github.com/parkerduckworth/lonchera/app/router"
+	"github.com/parkerduckworth/lonchera/recommender"
 )
 
 // Run sets up all application dependencies
@@ -20,9 +22,17 @@ func Run() {
 	r.SetTrustedProxies(nil)
 	router.SetupRoutes(r)
 
-	err := router.PingWeaviate(config.Conf.Weaviate)
-	if err != nil {
-		log.Fatal(err)
+	if config.Conf.Store.Backend != config.StoreMemory {
+		err := router.PingWeaviate(config.Conf.Weaviate)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// refuse to serve a dataset the queries don't match,
+		// rather than failing on every request
+		if err := recommender.CheckSchema(context.Background()); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	r.Run(":" + config.Conf.Server.HTTPPort)
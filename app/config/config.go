@@ -9,6 +9,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
 	"github.com/spf13/viper"
@@ -16,19 +17,65 @@ import (
 
 var Conf Config
 
+// Storage backends which may be selected by Config.Store.Backend
+const (
+	StoreWeaviate = "weaviate"
+	StoreMemory   = "memory"
+)
+
 // Config structures the environment configuration which is read
 // in from a YAML file. The file contents should match the structure
 // of this type
 type Config struct {
-	Env    string
+	Env string
+
+	// TimeZone is the time zone trucks' open hours are read in,
+	// unless their city has its own in Cities
+	TimeZone string
+	Cities   map[string]struct {
+		TimeZone string
+	}
+
 	Server struct {
 		HTTPPort     string
 		ReadTimeout  string
 		WriteTimeout string
+
+		// CacheMaxAge is how long responses to GET requests may
+		// be cached for. Zero stops them from being cached
+		CacheMaxAge time.Duration
 	}
 	Logger struct {
 		Level string
 	}
+	Ask struct {
+		MinCertainty float32
+		RelaxFloor   float32
+		RelaxStep    float32
+	}
+	Permits struct {
+		ActiveStatuses []string
+
+		// IgnoreExpiration recommends trucks whose permit has
+		// expired, for demos of datasets which are out of date
+		IgnoreExpiration bool
+	}
+	Ranking struct {
+		RelevanceWeight float32
+		DistanceWeight  float32
+		Decay           string
+		DecayMiles      float32
+	}
+	Store struct {
+		Backend     string
+		CSVPath     string
+		MappingPath string
+		Replica     struct {
+			Enabled         bool
+			RefreshInterval time.Duration
+			StaleAfter      time.Duration
+		}
+	}
 	Weaviate weaviate.Config
 }
 
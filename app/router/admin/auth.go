@@ -0,0 +1,43 @@
+// Package admin provides the handlers changing food trucks one at a
+// time, rather than by importing a whole dataset. Every route requires
+// the admin bearer token, and every change is written to the audit log
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkerduckworth/lonchera/failure"
+)
+
+// TokenEnv names the environment variable holding the admin bearer
+// token. It is a secret, so it is never read from the env config
+const TokenEnv = "LONCHERA_ADMIN_TOKEN"
+
+// Authorize is middleware rejecting requests which don't
+// carry the token as an Authorization bearer token
+func Authorize(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			ferr := failure.NewError(http.StatusUnauthorized, "invalid admin token", nil)
+
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// bearerToken returns the token of a bearer Authorization header
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return header[len(prefix):], true
+}
@@ -0,0 +1,240 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-openapi/strfmt"
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender"
+)
+
+// actorHeader optionally names the person making a change,
+// which is recorded in the audit log
+const actorHeader = "X-Admin-Actor"
+
+// createRequest holds a new truck's properties, named
+// as they are in the recommendation responses
+type createRequest struct {
+	City           string  `json:"city"`
+	LocationID     string  `json:"locationId"`
+	Name           string  `json:"name"`
+	FacilityType   string  `json:"facilityType"`
+	Fare           string  `json:"fare"`
+	Address        string  `json:"address"`
+	Latitude       float32 `json:"latitude"`
+	Longitude      float32 `json:"longitude"`
+	Status         string  `json:"status"`
+	ExpirationDate string  `json:"expirationDate"`
+	Schedule       string  `json:"schedule"`
+	Hours          string  `json:"hours"`
+}
+
+func (r *createRequest) record() (permit.Record, *failure.Error) {
+	rec := permit.Record{
+		City:         r.City,
+		LocationID:   r.LocationID,
+		Applicant:    r.Name,
+		FacilityType: r.FacilityType,
+		FoodItems:    r.Fare,
+		Address:      r.Address,
+		Latitude:     r.Latitude,
+		Longitude:    r.Longitude,
+		Status:       r.Status,
+		Schedule:     r.Schedule,
+		DaysHours:    r.Hours,
+	}
+
+	if r.ExpirationDate != "" {
+		exp, err := permit.ParseDate(r.ExpirationDate)
+		if err != nil {
+			return rec, failure.NewError(http.StatusBadRequest, "invalid expirationDate", err)
+		}
+		rec.ExpirationDate = exp
+	}
+
+	return rec, nil
+}
+
+// updateRequest holds the properties of a truck to change. Omitted
+// fields are left as they are, and an empty expirationDate clears it
+type updateRequest struct {
+	Name           *string  `json:"name"`
+	FacilityType   *string  `json:"facilityType"`
+	Fare           *string  `json:"fare"`
+	Address        *string  `json:"address"`
+	Latitude       *float32 `json:"latitude"`
+	Longitude      *float32 `json:"longitude"`
+	Status         *string  `json:"status"`
+	ExpirationDate *string  `json:"expirationDate"`
+	Schedule       *string  `json:"schedule"`
+	Hours          *string  `json:"hours"`
+}
+
+func (r *updateRequest) patch() (recommender.TruckPatch, *failure.Error) {
+	p := recommender.TruckPatch{
+		Name:         r.Name,
+		FacilityType: r.FacilityType,
+		Fare:         r.Fare,
+		Address:      r.Address,
+		Latitude:     r.Latitude,
+		Longitude:    r.Longitude,
+		Status:       r.Status,
+		Schedule:     r.Schedule,
+		Hours:        r.Hours,
+	}
+
+	if r.ExpirationDate != nil {
+		var exp time.Time
+		if *r.ExpirationDate != "" {
+			var err error
+			if exp, err = permit.ParseDate(*r.ExpirationDate); err != nil {
+				return p, failure.NewError(http.StatusBadRequest, "invalid expirationDate", err)
+			}
+		}
+		p.ExpirationDate = &exp
+	}
+
+	return p, nil
+}
+
+// Create is a handler func for adding a single food truck. Its
+// ID is derived from its city and locationId, as on import
+func Create(c *gin.Context) {
+	var request createRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ferr := failure.NewError(http.StatusBadRequest, "invalid request body", err)
+
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	rec, ferr := request.record()
+	if ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	data, ferr := recommender.CreateTruck(c, rec)
+	if ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	audit(c, "create", data.ID, map[string]interface{}{"truck": fields(data)})
+	c.JSON(http.StatusCreated, data)
+}
+
+// Update is a handler func for changing some of the
+// properties of the food truck with the given ID
+func Update(c *gin.Context) {
+	id, ok := truckID(c)
+	if !ok {
+		return
+	}
+
+	var request updateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ferr := failure.NewError(http.StatusBadRequest, "invalid request body", err)
+
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	patch, ferr := request.patch()
+	if ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	before, after, ferr := recommender.UpdateTruck(c, id, patch)
+	if ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	audit(c, "update", id, map[string]interface{}{"changes": changes(before, after)})
+	c.JSON(http.StatusOK, after)
+}
+
+// Delete is a handler func for deleting the food truck with the given ID
+func Delete(c *gin.Context) {
+	id, ok := truckID(c)
+	if !ok {
+		return
+	}
+
+	data, ferr := recommender.DeleteTruck(c, id)
+	if ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	audit(c, "delete", id, map[string]interface{}{"truck": fields(data)})
+	c.Status(http.StatusNoContent)
+}
+
+// truckID returns the ID in the path, or aborts
+// the request if it isn't a UUID
+func truckID(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if !strfmt.IsUUID(id) {
+		ferr := failure.NewError(http.StatusBadRequest, "id must be a UUID", nil)
+
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return "", false
+	}
+	return id, true
+}
+
+// audit writes an audit log entry for a change to the truck
+func audit(c *gin.Context, action, id string, extra map[string]interface{}) {
+	entry := map[string]interface{}{
+		"action":   action,
+		"truck_id": id,
+		"client":   c.ClientIP(),
+	}
+	if actor := c.GetHeader(actorHeader); actor != "" {
+		entry["actor"] = actor
+	}
+	for k, v := range extra {
+		entry[k] = v
+	}
+
+	log.Audit(entry, "admin "+action+" of food truck "+id)
+}
+
+// fields returns the truck's stored properties, named as they are in
+// responses. Whether the truck is open isn't stored, and is left out
+func fields(d *recommender.Detail) map[string]interface{} {
+	b, _ := json.Marshal(d)
+
+	var m map[string]interface{}
+	json.Unmarshal(b, &m)
+	delete(m, "open")
+	return m
+}
+
+// changes returns the properties which differ between
+// before and after, with their values before and after
+func changes(before, after *recommender.Detail) map[string][2]interface{} {
+	b, a := fields(before), fields(after)
+
+	diff := make(map[string][2]interface{})
+	for k, v := range a {
+		if !reflect.DeepEqual(b[k], v) {
+			diff[k] = [2]interface{}{b[k], v}
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			diff[k] = [2]interface{}{v, nil}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,22 @@
+// Package city provides the handlers describing the
+// cities whose permit datasets have been loaded
+package city
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkerduckworth/lonchera/recommender"
+)
+
+// List is a handler func for listing the loaded cities,
+// with the number of trucks and bounding box of each
+func List(c *gin.Context) {
+	data, ferr := recommender.Cities(c)
+	if ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
@@ -0,0 +1,25 @@
+package foodtruck
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkerduckworth/lonchera/failure"
+)
+
+// bindRequest binds the request's JSON body into request, or its
+// query string for a GET request, so that a recommendation can be
+// linked to. Query parameters are named by the form tags
+func bindRequest(c *gin.Context, request interface{}) *failure.Error {
+	if c.Request.Method == http.MethodGet {
+		if err := c.ShouldBindQuery(request); err != nil {
+			return failure.NewError(http.StatusBadRequest, "invalid query string", err)
+		}
+		return nil
+	}
+
+	if err := c.ShouldBindJSON(request); err != nil {
+		return failure.NewError(http.StatusBadRequest, "invalid request body", err)
+	}
+	return nil
+}
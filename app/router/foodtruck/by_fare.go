@@ -2,6 +2,7 @@ package foodtruck
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/parkerduckworth/lonchera/failure"
@@ -9,8 +10,11 @@ import (
 )
 
 type fareRequest struct {
-	Question string `json:"question"`
-	Limit    int    `json:"limit"`
+	Question string `json:"question" form:"question"`
+	Limit    int    `json:"limit" form:"limit"`
+	certaintyParams
+	filterParams
+	pageParams
 }
 
 func (r *fareRequest) validate() *failure.Error {
@@ -18,6 +22,18 @@ func (r *fareRequest) validate() *failure.Error {
 		return failure.NewError(http.StatusBadRequest, "must provide question", nil)
 	}
 
+	if ferr := r.certaintyParams.validate(); ferr != nil {
+		return ferr
+	}
+
+	if ferr := r.filterParams.validate(); ferr != nil {
+		return ferr
+	}
+
+	if ferr := r.pageParams.validate(); ferr != nil {
+		return ferr
+	}
+
 	if r.Limit < 1 {
 		r.Limit = defaultQueryLimit
 	}
@@ -29,10 +45,19 @@ func (r *fareRequest) validate() *failure.Error {
 // based on a provided question or statement indicating
 // which type of food items are desired
 func ByFare(c *gin.Context) {
-	var request fareRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		ferr := failure.NewError(http.StatusBadRequest, "invalid request body", err)
+	byFare(c, v1)
+}
 
+// ByFareV2 is ByFare, responding with a v2 envelope
+func ByFareV2(c *gin.Context) {
+	byFare(c, v2)
+}
+
+func byFare(c *gin.Context, version apiVersion) {
+	start := time.Now()
+
+	var request fareRequest
+	if ferr := bindRequest(c, &request); ferr != nil {
 		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
 		return
 	}
@@ -42,11 +67,26 @@ func ByFare(c *gin.Context) {
 		return
 	}
 
-	data, ferr := recommender.ByFare(c, request.Question, request.Limit)
+	var (
+		data *recommender.Response
+		page *recommender.PagedResponse
+		meta *recommender.Meta
+		ferr *failure.Error
+	)
+	if request.paged() {
+		page, meta, ferr = recommender.ByFarePage(c, request.Question, request.certainty(), request.filter(), request.page())
+	} else {
+		data, meta, ferr = recommender.ByFare(c, request.Question, request.certainty(), request.filter(), request.Limit)
+	}
+
 	if ferr != nil {
 		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
 		return
 	}
 
-	c.JSON(http.StatusOK, data)
+	if version == v2 {
+		recommender.CountByFare(c, request.Question, meta)
+	}
+
+	respond(c, version, start, &request, data, page, meta)
 }
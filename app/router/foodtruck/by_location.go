@@ -2,23 +2,33 @@ package foodtruck
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/parkerduckworth/lonchera/failure"
 	"github.com/parkerduckworth/lonchera/recommender"
-	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/filters"
 )
 
 type locationRequest struct {
-	Latitude     float32 `json:"latitude"`
-	Longitude    float32 `json:"longitude"`
-	MaxMilesAway float32 `json:"maxMilesAway"`
-	Limit        int     `json:"limit"`
+	Latitude     float32 `json:"latitude" form:"lat"`
+	Longitude    float32 `json:"longitude" form:"lng"`
+	MaxMilesAway float32 `json:"maxMilesAway" form:"maxMilesAway"`
+	Limit        int     `json:"limit" form:"limit"`
+	filterParams
+	pageParams
 }
 
 func (r *locationRequest) validate() *failure.Error {
-	if r.MaxMilesAway == 0 {
-		return failure.NewError(http.StatusBadRequest, "must provide maxMilesAway", nil)
+	if r.MaxMilesAway < 0 {
+		return failure.NewError(http.StatusBadRequest, "maxMilesAway must not be negative", nil)
+	}
+
+	if ferr := r.filterParams.validate(); ferr != nil {
+		return ferr
+	}
+
+	if ferr := r.pageParams.validate(); ferr != nil {
+		return ferr
 	}
 
 	if r.Limit < 1 {
@@ -29,12 +39,23 @@ func (r *locationRequest) validate() *failure.Error {
 }
 
 // ByLocation is a handler func for fetching food trucks
-// near a given set of geo coordinates
+// near a given set of geo coordinates, nearest first. When
+// maxMilesAway is omitted, the closest trucks are returned
+// regardless of distance
 func ByLocation(c *gin.Context) {
-	var request locationRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		ferr := failure.NewError(http.StatusBadRequest, "invalid request body", err)
+	byLocation(c, v1)
+}
+
+// ByLocationV2 is ByLocation, responding with a v2 envelope
+func ByLocationV2(c *gin.Context) {
+	byLocation(c, v2)
+}
 
+func byLocation(c *gin.Context, version apiVersion) {
+	start := time.Now()
+
+	var request locationRequest
+	if ferr := bindRequest(c, &request); ferr != nil {
 		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
 		return
 	}
@@ -44,18 +65,34 @@ func ByLocation(c *gin.Context) {
 		return
 	}
 
-	data, ferr := recommender.ByLocation(c, &filters.GeoCoordinatesParameter{
+	coord := &recommender.GeoCoordinates{
 		Latitude:    request.Latitude,
 		Longitude:   request.Longitude,
 		MaxDistance: milesToMeters(request.MaxMilesAway),
-	}, request.Limit)
+	}
+
+	var (
+		data *recommender.Response
+		page *recommender.PagedResponse
+		meta *recommender.Meta
+		ferr *failure.Error
+	)
+	if request.paged() {
+		page, meta, ferr = recommender.ByLocationPage(c, coord, request.filter(), request.page())
+	} else {
+		data, meta, ferr = recommender.ByLocation(c, coord, request.filter(), request.Limit)
+	}
 
 	if ferr != nil {
 		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
 		return
 	}
 
-	c.JSON(http.StatusOK, data)
+	if version == v2 {
+		recommender.CountByLocation(c, coord, meta)
+	}
+
+	respond(c, version, start, &request, data, page, meta)
 }
 
 func milesToMeters(m float32) float32 {
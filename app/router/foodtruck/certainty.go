@@ -0,0 +1,61 @@
+package foodtruck
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender"
+)
+
+// Response headers reporting how the results were found
+const (
+	// certaintyHeader is the certainty threshold the results were found with
+	certaintyHeader = "X-Certainty"
+
+	// datasetVersionHeader is the version of the dataset which was queried
+	datasetVersionHeader = "X-Dataset-Version"
+)
+
+// certaintyParams are the request fields shared by
+// every handler which asks a question
+type certaintyParams struct {
+	MinCertainty *float32 `json:"minCertainty" form:"minCertainty"`
+	Relax        bool     `json:"relax" form:"relax"`
+}
+
+func (p *certaintyParams) validate() *failure.Error {
+	if p.MinCertainty != nil && (*p.MinCertainty < 0 || *p.MinCertainty > 1) {
+		return failure.NewError(http.StatusBadRequest, "minCertainty must be between 0 and 1", nil)
+	}
+
+	// the default is filled in, so that v2 echoes the threshold asked for
+	if p.MinCertainty == nil {
+		min := recommender.DefaultCertainty().Min
+		p.MinCertainty = &min
+	}
+
+	return nil
+}
+
+// certainty overlays the requested threshold onto the default
+func (p *certaintyParams) certainty() recommender.Certainty {
+	c := recommender.DefaultCertainty()
+	if p.MinCertainty != nil {
+		c.Min = *p.MinCertainty
+	}
+	c.Relax = p.Relax
+
+	return c
+}
+
+func setMetaHeaders(c *gin.Context, meta *recommender.Meta) {
+	if meta.Certainty > 0 {
+		c.Header(certaintyHeader, strconv.FormatFloat(float64(meta.Certainty), 'f', -1, 32))
+	}
+
+	if meta.DatasetVersion != "" {
+		c.Header(datasetVersionHeader, meta.DatasetVersion)
+	}
+}
@@ -0,0 +1,30 @@
+package foodtruck
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-openapi/strfmt"
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender"
+)
+
+// Get is a handler func for fetching every stored
+// property of the food truck with the given ID
+func Get(c *gin.Context) {
+	id := c.Param("id")
+	if !strfmt.IsUUID(id) {
+		ferr := failure.NewError(http.StatusBadRequest, "id must be a UUID", nil)
+
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	data, ferr := recommender.GetTruck(c, id)
+	if ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
@@ -0,0 +1,44 @@
+package foodtruck
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender"
+)
+
+// filterParams are the request fields shared by every handler
+// which recommends trucks
+type filterParams struct {
+	// City restricts the results to one city, e.g. san-francisco
+	City string `json:"city,omitempty" form:"city"`
+
+	OpenAt   *time.Time `json:"openAt,omitempty" form:"openAt"`
+	OpenOnly bool       `json:"openOnly" form:"openOnly"`
+
+	// IncludeInactive returns trucks with unapproved or expired permits
+	IncludeInactive bool `json:"includeInactive" form:"includeInactive"`
+}
+
+func (p *filterParams) validate() *failure.Error {
+	if p.City != "" && !permit.ValidCity(p.City) {
+		return failure.NewError(http.StatusBadRequest, recommender.ErrInvalidCity, nil)
+	}
+
+	return nil
+}
+
+func (p *filterParams) filter() recommender.Filter {
+	f := recommender.Filter{
+		City:            p.City,
+		OpenOnly:        p.OpenOnly,
+		IncludeInactive: p.IncludeInactive,
+	}
+	if p.OpenAt != nil {
+		f.OpenAt = *p.OpenAt
+	}
+
+	return f
+}
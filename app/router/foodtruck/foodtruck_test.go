@@ -0,0 +1,326 @@
+package foodtruck
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+const (
+	lat = 37.7749
+	lng = -122.4194
+)
+
+var engine *gin.Engine
+
+func TestMain(m *testing.M) {
+	config.Conf.TimeZone = "UTC"
+	config.Conf.Logger.Level = "ERROR"
+	config.Conf.Server.CacheMaxAge = time.Minute
+	config.Conf.Ask.MinCertainty = 0.3
+	config.Conf.Ask.RelaxFloor = 0.1
+	config.Conf.Ask.RelaxStep = 0.1
+	config.Conf.Permits.ActiveStatuses = []string{permit.StatusApproved}
+	config.Conf.Ranking.RelevanceWeight = 0.7
+	config.Conf.Ranking.DistanceWeight = 0.3
+	config.Conf.Ranking.Decay = recommender.DecayExponential
+	config.Conf.Ranking.DecayMiles = 0.5
+	log.Setup()
+
+	// trucks north of lat, lng, nearest first
+	recs := []permit.Record{
+		{LocationID: "1", Applicant: "Near Tacos", FoodItems: "Tacos: Quesadillas", Latitude: lat + 0.001},
+		{LocationID: "2", Applicant: "Burger Barn", FoodItems: "Burgers: Fries", Latitude: lat + 0.004},
+		{LocationID: "3", Applicant: "Far Tacos", FoodItems: "Tacos: Burritos", Latitude: lat + 0.027},
+	}
+	for i := range recs {
+		recs[i].City = permit.DefaultCity
+		recs[i].FacilityType = "Truck"
+		recs[i].Status = permit.StatusApproved
+		recs[i].ExpirationDate = time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+		recs[i].Longitude = lng
+		recs[i].LocationSource = permit.LocationSourceDataset
+	}
+	recommender.SetStore(store.NewMemory(recs))
+
+	gin.SetMode(gin.TestMode)
+	engine = gin.New()
+	for _, v := range []struct {
+		prefix                        string
+		byFare, byLocation, recommend gin.HandlerFunc
+	}{
+		{"/api/v1/foodtrucks", ByFare, ByLocation, Recommend},
+		{"/api/v2/foodtrucks", ByFareV2, ByLocationV2, RecommendV2},
+	} {
+		g := engine.Group(v.prefix)
+		g.GET("/by-fare", v.byFare)
+		g.POST("/by-fare", v.byFare)
+		g.GET("/by-location", v.byLocation)
+		g.POST("/by-location", v.byLocation)
+		g.POST("/recommend", v.recommend)
+	}
+
+	os.Exit(m.Run())
+}
+
+// serve sends the request, with body encoded as JSON unless it is nil
+func serve(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+// v2Body is a v2 envelope, with the fields the tests check
+type v2Body struct {
+	Data []recommender.Result `json:"data"`
+	Meta struct {
+		Certainty  float32  `json:"certainty"`
+		Limit      int      `json:"limit"`
+		Total      *int     `json:"total"`
+		ElapsedMs  *float64 `json:"elapsedMs"`
+		NextCursor string   `json:"nextCursor"`
+		HasMore    *bool    `json:"hasMore"`
+	} `json:"meta"`
+	Query map[string]interface{} `json:"query"`
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode %s: %s", w.Body, err)
+	}
+}
+
+func checkNames(t *testing.T, results []recommender.Result, want ...string) {
+	t.Helper()
+
+	var got []string
+	for _, res := range results {
+		got = append(got, res.Name)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestByLocationV1(t *testing.T) {
+	w := serve(t, http.MethodPost, "/api/v1/foodtrucks/by-location",
+		map[string]interface{}{"latitude": lat, "longitude": lng, "limit": 2})
+
+	var results []recommender.Result
+	decode(t, w, &results)
+	checkNames(t, results, "Near Tacos", "Burger Barn")
+
+	if cc := w.Header().Get("Cache-Control"); cc != "" {
+		t.Errorf("POST response has Cache-Control %q", cc)
+	}
+}
+
+func TestByLocationV2(t *testing.T) {
+	w := serve(t, http.MethodGet, "/api/v2/foodtrucks/by-location?lat=37.7749&lng=-122.4194&limit=2", nil)
+
+	var body v2Body
+	decode(t, w, &body)
+	checkNames(t, body.Data, "Near Tacos", "Burger Barn")
+
+	if body.Meta.Limit != 2 {
+		t.Errorf("meta.limit = %d, want 2", body.Meta.Limit)
+	}
+	if body.Meta.Total == nil || *body.Meta.Total != 3 {
+		t.Errorf("meta.total = %v, want 3", body.Meta.Total)
+	}
+	if body.Meta.ElapsedMs == nil {
+		t.Error("meta.elapsedMs is missing")
+	}
+	if body.Meta.HasMore != nil || body.Meta.NextCursor != "" {
+		t.Error("unpaged response has a cursor")
+	}
+
+	if body.Query["limit"] != 2.0 || body.Query["maxMilesAway"] != 0.0 {
+		t.Errorf("query = %v, want the normalized request", body.Query)
+	}
+
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Errorf("Cache-Control = %q, want public, max-age=60", cc)
+	}
+}
+
+func TestByLocationV2Page(t *testing.T) {
+	request := map[string]interface{}{"latitude": lat, "longitude": lng, "pageSize": 2}
+
+	var first v2Body
+	decode(t, serve(t, http.MethodPost, "/api/v2/foodtrucks/by-location", request), &first)
+	checkNames(t, first.Data, "Near Tacos", "Burger Barn")
+
+	if first.Meta.HasMore == nil || !*first.Meta.HasMore || first.Meta.NextCursor == "" {
+		t.Fatalf("first page has no more results: %+v", first.Meta)
+	}
+
+	request["cursor"] = first.Meta.NextCursor
+	delete(request, "pageSize")
+
+	var next v2Body
+	decode(t, serve(t, http.MethodPost, "/api/v2/foodtrucks/by-location", request), &next)
+	checkNames(t, next.Data, "Far Tacos")
+
+	if next.Meta.HasMore == nil || *next.Meta.HasMore || next.Meta.NextCursor != "" {
+		t.Errorf("last page has more results: %+v", next.Meta)
+	}
+	if next.Meta.Limit != 2 {
+		t.Errorf("meta.limit = %d, want the first page's size", next.Meta.Limit)
+	}
+}
+
+func TestByFareV2(t *testing.T) {
+	w := serve(t, http.MethodPost, "/api/v2/foodtrucks/by-fare",
+		map[string]interface{}{"question": "tacos burritos"})
+
+	var body v2Body
+	decode(t, w, &body)
+	checkNames(t, body.Data, "Far Tacos", "Near Tacos")
+
+	if body.Meta.Certainty != 0.3 {
+		t.Errorf("meta.certainty = %v, want 0.3", body.Meta.Certainty)
+	}
+	if body.Meta.Total == nil || *body.Meta.Total != 2 {
+		t.Errorf("meta.total = %v, want 2", body.Meta.Total)
+	}
+
+	// defaults are filled into the echoed query
+	if body.Query["minCertainty"] != 0.3 || body.Query["limit"] != float64(defaultQueryLimit) {
+		t.Errorf("query = %v, want the defaults filled in", body.Query)
+	}
+
+	if h := w.Header().Get(certaintyHeader); h != "0.3" {
+		t.Errorf("%s = %q, want 0.3", certaintyHeader, h)
+	}
+}
+
+func TestRecommendV2(t *testing.T) {
+	w := serve(t, http.MethodPost, "/api/v2/foodtrucks/recommend", map[string]interface{}{
+		"question":        "tacos burritos",
+		"latitude":        lat,
+		"longitude":       lng,
+		"maxMilesAway":    5,
+		"distanceWeight":  1,
+		"relevanceWeight": 0,
+	})
+
+	var body v2Body
+	decode(t, w, &body)
+	checkNames(t, body.Data, "Near Tacos", "Far Tacos")
+
+	for _, res := range body.Data {
+		if res.Scores == nil || res.Location == nil || res.Location.MilesAway == 0 {
+			t.Errorf("%s has no scores or distance", res.Name)
+		}
+	}
+
+	if body.Query["decay"] != recommender.DecayExponential || body.Query["decayMiles"] != 0.5 {
+		t.Errorf("query = %v, want the default decay filled in", body.Query)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		msg    string
+	}{
+		{
+			name:   "no question",
+			method: http.MethodPost,
+			path:   "/api/v1/foodtrucks/by-fare",
+			body:   map[string]interface{}{"limit": 1},
+			msg:    "must provide question",
+		},
+		{
+			name:   "malformed city",
+			method: http.MethodGet,
+			path:   `/api/v1/foodtrucks/by-fare?question=tacos&city=sf"}`,
+			msg:    recommender.ErrInvalidCity,
+		},
+		{
+			name:   "certainty out of range",
+			method: http.MethodPost,
+			path:   "/api/v2/foodtrucks/by-fare",
+			body:   map[string]interface{}{"question": "tacos", "minCertainty": 2},
+			msg:    "minCertainty must be between 0 and 1",
+		},
+		{
+			name:   "page too large",
+			method: http.MethodPost,
+			path:   "/api/v1/foodtrucks/by-location",
+			body:   map[string]interface{}{"latitude": lat, "longitude": lng, "pageSize": 101},
+			msg:    "pageSize must be between 1 and 100",
+		},
+		{
+			name:   "malformed query string",
+			method: http.MethodGet,
+			path:   "/api/v2/foodtrucks/by-location?lat=north",
+			msg:    "invalid query string",
+		},
+		{
+			name:   "malformed cursor",
+			method: http.MethodGet,
+			path:   "/api/v2/foodtrucks/by-location?lat=37.7749&lng=-122.4194&cursor=nope",
+			msg:    recommender.ErrInvalidCursor,
+		},
+		{
+			name:   "recommend without range",
+			method: http.MethodPost,
+			path:   "/api/v1/foodtrucks/recommend",
+			body:   map[string]interface{}{"question": "tacos", "latitude": lat, "longitude": lng},
+			msg:    "must provide maxMilesAway",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, tt.method, tt.path, tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+
+			var body struct{ Message string }
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Message != tt.msg {
+				t.Errorf("message = %q, want %q", body.Message, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package foodtruck
+
+import (
+	"net/http"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender"
+)
+
+// maxPageSize bounds the number of results in a page
+const maxPageSize = 100
+
+// pageParams are the request fields of handlers which page through
+// their results. Setting either field wraps the results in a page
+// envelope, otherwise limit is used and a bare array is returned
+type pageParams struct {
+	PageSize int    `json:"pageSize,omitempty" form:"pageSize"`
+	Cursor   string `json:"cursor,omitempty" form:"cursor"`
+}
+
+func (p *pageParams) validate() *failure.Error {
+	if p.PageSize < 0 || p.PageSize > maxPageSize {
+		return failure.NewError(http.StatusBadRequest, "pageSize must be between 1 and 100", nil)
+	}
+
+	return nil
+}
+
+// paged reports whether the request asked for a page
+func (p *pageParams) paged() bool {
+	return p.PageSize > 0 || p.Cursor != ""
+}
+
+func (p *pageParams) page() recommender.Page {
+	return recommender.Page{Size: p.PageSize, Cursor: p.Cursor}
+}
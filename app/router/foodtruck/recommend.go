@@ -0,0 +1,142 @@
+package foodtruck
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender"
+)
+
+type recommendRequest struct {
+	Question     string  `json:"question"`
+	Latitude     float32 `json:"latitude"`
+	Longitude    float32 `json:"longitude"`
+	MaxMilesAway float32 `json:"maxMilesAway"`
+	Limit        int     `json:"limit"`
+
+	// optional ranking overrides, defaulting to the configured weights
+	RelevanceWeight *float32 `json:"relevanceWeight"`
+	DistanceWeight  *float32 `json:"distanceWeight"`
+	Decay           string   `json:"decay"`
+	DecayMiles      *float32 `json:"decayMiles"`
+
+	certaintyParams
+	filterParams
+}
+
+func (r *recommendRequest) validate() *failure.Error {
+	if len(r.Question) == 0 {
+		return failure.NewError(http.StatusBadRequest, "must provide question", nil)
+	}
+
+	if ferr := r.certaintyParams.validate(); ferr != nil {
+		return ferr
+	}
+
+	if ferr := r.filterParams.validate(); ferr != nil {
+		return ferr
+	}
+
+	if r.MaxMilesAway <= 0 {
+		return failure.NewError(http.StatusBadRequest, "must provide maxMilesAway", nil)
+	}
+
+	if r.Limit < 1 {
+		r.Limit = defaultQueryLimit
+	}
+
+	if (r.RelevanceWeight != nil && *r.RelevanceWeight < 0) ||
+		(r.DistanceWeight != nil && *r.DistanceWeight < 0) {
+		return failure.NewError(http.StatusBadRequest, "weights must not be negative", nil)
+	}
+
+	if r.Decay != "" && r.Decay != recommender.DecayLinear && r.Decay != recommender.DecayExponential {
+		return failure.NewError(http.StatusBadRequest, "decay must be linear or exponential", nil)
+	}
+
+	if r.DecayMiles != nil && *r.DecayMiles <= 0 {
+		return failure.NewError(http.StatusBadRequest, "decayMiles must be positive", nil)
+	}
+
+	// the weights are filled in from the defaults,
+	// so that v2 echoes the ones which were used
+	w := r.weights()
+	r.RelevanceWeight, r.DistanceWeight = &w.Relevance, &w.Distance
+	r.Decay, r.DecayMiles = w.Decay, &w.DecayMiles
+
+	return nil
+}
+
+// weights overlays the requested ranking weights onto the defaults
+func (r *recommendRequest) weights() recommender.Weights {
+	w := recommender.DefaultWeights()
+
+	if r.RelevanceWeight != nil {
+		w.Relevance = *r.RelevanceWeight
+	}
+
+	if r.DistanceWeight != nil {
+		w.Distance = *r.DistanceWeight
+	}
+
+	if r.Decay != "" {
+		w.Decay = r.Decay
+	}
+
+	if r.DecayMiles != nil {
+		w.DecayMiles = *r.DecayMiles
+	}
+
+	return w
+}
+
+// Recommend is a handler func for fetching food trucks
+// which serve the desired fare near a given set of geo
+// coordinates
+func Recommend(c *gin.Context) {
+	recommend(c, v1)
+}
+
+// RecommendV2 is Recommend, responding with a v2 envelope
+func RecommendV2(c *gin.Context) {
+	recommend(c, v2)
+}
+
+func recommend(c *gin.Context, version apiVersion) {
+	start := time.Now()
+
+	var request recommendRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ferr := failure.NewError(http.StatusBadRequest, "invalid request body", err)
+
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	if ferr := (&request).validate(); ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	coord := &recommender.GeoCoordinates{
+		Latitude:    request.Latitude,
+		Longitude:   request.Longitude,
+		MaxDistance: milesToMeters(request.MaxMilesAway),
+	}
+
+	data, meta, ferr := recommender.Recommend(c, request.Question, coord,
+		request.weights(), request.certainty(), request.filter(), request.Limit)
+
+	if ferr != nil {
+		c.AbortWithStatusJSON(ferr.StatusCode, ferr)
+		return
+	}
+
+	if version == v2 {
+		recommender.CountRecommend(c, request.Question, coord, meta)
+	}
+
+	respond(c, version, start, &request, data, nil, meta)
+}
@@ -0,0 +1,87 @@
+package foodtruck
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/recommender"
+)
+
+// apiVersion selects the shape of a handler's response
+type apiVersion int
+
+const (
+	// v1 responds with a bare array of results, or a page envelope
+	// when paged, and reports metadata in response headers only
+	v1 apiVersion = iota + 1
+
+	// v2 responds with an envelope holding the results, their
+	// metadata, and the query they were found with
+	v2
+)
+
+// envelope is the body of every v2 response
+type envelope struct {
+	Data  *recommender.Response `json:"data"`
+	Meta  envelopeMeta          `json:"meta"`
+	Query interface{}           `json:"query"`
+}
+
+// envelopeMeta extends the recommender's metadata
+// with the time taken and, for a page, its cursor
+type envelopeMeta struct {
+	*recommender.Meta
+	ElapsedMs  float64 `json:"elapsedMs"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+	HasMore    *bool   `json:"hasMore,omitempty"`
+}
+
+// respond writes the results in the shape of the API version. query is
+// the normalized request, and exactly one of data and page is set
+func respond(c *gin.Context, version apiVersion, start time.Time, query interface{},
+	data *recommender.Response, page *recommender.PagedResponse, meta *recommender.Meta) {
+
+	setMetaHeaders(c, meta)
+	if c.Request.Method == http.MethodGet {
+		setCacheHeaders(c)
+	}
+
+	if version == v1 {
+		if page != nil {
+			c.JSON(http.StatusOK, page)
+		} else {
+			c.JSON(http.StatusOK, data)
+		}
+		return
+	}
+
+	env := envelope{
+		Data:  data,
+		Meta:  envelopeMeta{Meta: meta},
+		Query: query,
+	}
+	if page != nil {
+		env.Data = page.Data
+		env.Meta.NextCursor = page.NextCursor
+		env.Meta.HasMore = &page.HasMore
+	}
+	env.Meta.ElapsedMs = float64(time.Since(start).Microseconds()) / 1000
+
+	c.JSON(http.StatusOK, env)
+}
+
+// setCacheHeaders lets CDNs and browsers cache a GET response for the
+// configured max age. Trucks are flagged as open at the time of the
+// request, so the max age should be kept short
+func setCacheHeaders(c *gin.Context) {
+	maxAge := config.Conf.Server.CacheMaxAge
+	if maxAge <= 0 {
+		c.Header("Cache-Control", "no-cache")
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+}
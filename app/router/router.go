@@ -7,9 +7,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/parkerduckworth/lonchera/app/router/admin"
+	"github.com/parkerduckworth/lonchera/app/router/city"
 	"github.com/parkerduckworth/lonchera/app/router/foodtruck"
+	"github.com/parkerduckworth/lonchera/app/router/vendor"
 	"github.com/pkg/errors"
 	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
 )
@@ -34,6 +38,8 @@ func PingWeaviate(config weaviate.Config) error {
 func SetupRoutes(r *gin.Engine) {
 	apiRoutes := r.Group("/api")
 	setupV1Routes(apiRoutes)
+	setupV2Routes(apiRoutes)
+	setupAdminRoutes(apiRoutes)
 }
 
 func setupV1Routes(r *gin.RouterGroup) {
@@ -41,8 +47,52 @@ func setupV1Routes(r *gin.RouterGroup) {
 	{
 		foodtruckRoutes := v1Routes.Group("/foodtrucks")
 		{
+			foodtruckRoutes.GET("/by-fare", foodtruck.ByFare)
 			foodtruckRoutes.POST("/by-fare", foodtruck.ByFare)
+			foodtruckRoutes.GET("/by-location", foodtruck.ByLocation)
 			foodtruckRoutes.POST("/by-location", foodtruck.ByLocation)
+			foodtruckRoutes.POST("/recommend", foodtruck.Recommend)
+			foodtruckRoutes.GET("/:id", foodtruck.Get)
+		}
+
+		v1Routes.GET("/cities", city.List)
+		v1Routes.GET("/vendors/*name", vendor.Get)
+	}
+}
+
+// setupV2Routes serves the recommendation endpoints with
+// their results wrapped in an envelope of metadata
+func setupV2Routes(r *gin.RouterGroup) {
+	v2Routes := r.Group("/v2")
+	{
+		foodtruckRoutes := v2Routes.Group("/foodtrucks")
+		{
+			foodtruckRoutes.GET("/by-fare", foodtruck.ByFareV2)
+			foodtruckRoutes.POST("/by-fare", foodtruck.ByFareV2)
+			foodtruckRoutes.GET("/by-location", foodtruck.ByLocationV2)
+			foodtruckRoutes.POST("/by-location", foodtruck.ByLocationV2)
+			foodtruckRoutes.POST("/recommend", foodtruck.RecommendV2)
+		}
+	}
+}
+
+// setupAdminRoutes serves the routes changing food trucks one at a
+// time, behind the bearer token read from the environment. Without
+// a token, the routes aren't served at all
+func setupAdminRoutes(r *gin.RouterGroup) {
+	token := os.Getenv(admin.TokenEnv)
+	if token == "" {
+		log.Printf("%s is not set, admin routes are disabled", admin.TokenEnv)
+		return
+	}
+
+	adminRoutes := r.Group("/v1/admin", admin.Authorize(token))
+	{
+		foodtruckRoutes := adminRoutes.Group("/foodtrucks")
+		{
+			foodtruckRoutes.POST("", admin.Create)
+			foodtruckRoutes.PATCH("/:id", admin.Update)
+			foodtruckRoutes.DELETE("/:id", admin.Delete)
 		}
 	}
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+	"github.com/parkerduckworth/lonchera/snapshot"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+)
+
+func init() {
+	config.Setup()
+	log.Setup()
+}
+
+var (
+	classFlag = flag.String("class", "",
+		"FoodTruck class to export (default: the active class)")
+	formatFlag = flag.String("format", string(snapshot.FormatJSONL),
+		"format of the snapshot: jsonl, csv or geojson. Only jsonl can be restored")
+	outFlag = flag.String("out", "",
+		"file to write the snapshot to (default: stdout)")
+	vectorsFlag = flag.Bool("vectors", false,
+		"include each object's vector, so that a restore doesn't re-vectorize")
+	pageSizeFlag = flag.Int("page-size", store.DefaultPageSize,
+		"number of objects read from Weaviate per request")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	format := snapshot.Format(*formatFlag)
+	if !format.Valid() {
+		log.Fatalf("unknown -format %q", *formatFlag)
+	}
+
+	client := weaviate.New(config.Conf.Weaviate)
+
+	class := *classFlag
+	if class == "" {
+		active, err := store.ReadActiveDataset(ctx, client)
+		if err != nil {
+			log.Fatal(err)
+		}
+		class = active.Class
+	}
+
+	out := os.Stdout
+	if *outFlag != "" {
+		f, err := os.Create(*outFlag)
+		if err != nil {
+			log.Fatalf("failed to create snapshot: %s", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w, err := snapshot.NewWriter(out, format, *vectorsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var exported int
+	c := store.NewCursor(client, class, *pageSizeFlag, *vectorsFlag)
+	for {
+		page, err := c.Next(ctx)
+		if err != nil {
+			log.Fatalf("failed to export %s after %d objects: %s", class, exported, err)
+		}
+		if page == nil {
+			break
+		}
+
+		for _, t := range page {
+			if err := w.Write(t); err != nil {
+				log.Fatalf("failed to write snapshot: %s", err)
+			}
+		}
+
+		exported += len(page)
+		log.Debugf("exported %d objects", exported)
+	}
+
+	if err := w.Close(); err != nil {
+		log.Fatalf("failed to write snapshot: %s", err)
+	}
+
+	log.Infof("exported %d objects from %s\n", exported, class)
+}
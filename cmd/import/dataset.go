@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+// prepareClasses creates the class for the new dataset version, and the
+// pointer class if this is the first versioned import. It returns the
+// classes which already existed, by name
+func prepareClasses(ctx context.Context, client *weaviate.Client, version string) (map[string]*models.Class, error) {
+	dump, err := client.Schema().Getter().Do(ctx)
+	if err != nil {
+		return nil, failure.WeaviateError(err)
+	}
+
+	classes := make(map[string]*models.Class, len(dump.Classes))
+	for _, class := range dump.Classes {
+		classes[class.Class] = class
+	}
+
+	if _, err := store.CreateVersionedClass(ctx, client, version); err != nil {
+		return nil, err
+	}
+
+	return classes, nil
+}
+
+// activeTrucks returns every object of the active dataset, by ID. There
+// are none if the active class doesn't exist yet, or has a schema older
+// than the current one, whose objects can't be read back as trucks
+func activeTrucks(ctx context.Context, client *weaviate.Client, classes map[string]*models.Class,
+	active store.Dataset) (map[string]store.Truck, error) {
+
+	class, ok := classes[active.Class]
+	if !ok {
+		return map[string]store.Truck{}, nil
+	}
+
+	if diffs := schema.Diff(class); len(diffs) > 0 {
+		log.Warnf("active class %s does not match the current schema (%s), none of its objects are carried over",
+			active.Class, strings.Join(diffs, "; "))
+		return map[string]store.Truck{}, nil
+	}
+
+	trucks, err := store.AllInClass(ctx, client, active.Class)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]store.Truck, len(trucks))
+	for _, t := range trucks {
+		byID[t.ID] = t
+	}
+
+	return byID, nil
+}
+
+// tombstone returns a copy of the truck marked as removed
+func tombstone(t store.Truck) store.Truck {
+	t.Status = permit.StatusRemoved
+	return t
+}
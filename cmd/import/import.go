@@ -2,17 +2,22 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/go-openapi/strfmt"
 	"github.com/parkerduckworth/lonchera/app/config"
-	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/loader"
 	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
 	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/parkerduckworth/lonchera/recommender/store"
 	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
-	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/batch"
 	"github.com/semi-technologies/weaviate/entities/models"
 )
 
@@ -21,123 +26,299 @@ func init() {
 	log.Setup()
 }
 
-// Column numbers for each target field
-const (
-	ApplicantCol    = 1
-	FacilityTypeCol = 2
-	FoodItemsCol    = 11
-	LatitudeCol     = 14
-	LongitudeCol    = 15
-)
-
-const (
-	batchSize = 10
-	csvPath   = "cmd/import/Mobile_Food_Facility_Permit.csv"
+var (
+	fileFlag = flag.String("file", permit.DefaultCSVPath,
+		"permit dataset to import")
+	formatFlag = flag.String("format", "",
+		"format of the dataset: csv, json, soda or geojson (default: detected)")
+	mappingFlag = flag.String("mapping", "",
+		"YAML file mapping the dataset's columns onto FoodTruck properties (default: San Francisco layout)")
+	cityFlag = flag.String("city", "",
+		"key of the city being imported, overriding the mapping's city")
+	versionFlag = flag.String("version", time.Now().UTC().Format("20060102150405"),
+		"version the dataset is imported as, naming its class")
+	dropOldFlag = flag.Bool("drop-old", false,
+		"drop the previously active class once the new version is activated")
+	syncFlag = flag.Bool("sync", false,
+		"leave out objects of the city whose locationid no longer exists in the dataset")
+	tombstoneFlag = flag.Bool("tombstone", false,
+		"with -sync, carry vanished objects over with status "+permit.StatusRemoved+" instead of leaving them out")
+	dryRunFlag = flag.Bool("dry-run", false,
+		"validate every row and report, without touching Weaviate")
+	onErrorFlag = flag.String("on-error", onErrorSkip,
+		"how to handle rejected rows: skip, fail or quarantine")
+	rejectsFlag = flag.String("rejects", "rejects.csv",
+		"with -on-error=quarantine, CSV to copy rejected rows to")
+	reportFlag = flag.String("report", "",
+		"file to write the JSON import report to (default: stdout)")
+	batchSizeFlag = flag.Int("batch-size", loader.DefaultConfig().BatchSize,
+		"number of objects written in each batch")
+	workersFlag = flag.Int("workers", loader.DefaultConfig().Workers,
+		"number of batches written concurrently")
+	retriesFlag = flag.Int("retries", loader.DefaultConfig().MaxRetries,
+		"number of times a batch is retried after a transient Weaviate error")
 )
 
 func main() {
-	recs, err := readVectorFile()
+	flag.Parse()
+
+	// on interrupt, batches in flight are abandoned, and
+	// the report is written for the rows imported so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !schema.ValidVersion(*versionFlag) {
+		log.Fatalf("invalid -version %q, only letters, digits and underscores are allowed", *versionFlag)
+	}
+
+	switch *onErrorFlag {
+	case onErrorSkip, onErrorFail, onErrorQuarantine:
+	default:
+		log.Fatalf("unknown -on-error policy %q", *onErrorFlag)
+	}
+
+	mapping := permit.DefaultMapping()
+	if *mappingFlag != "" {
+		var err error
+		if mapping, err = permit.LoadMapping(*mappingFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *cityFlag != "" {
+		if !permit.ValidCity(*cityFlag) {
+			log.Fatalf("-city %q must be lower case letters, digits and dashes", *cityFlag)
+		}
+		mapping.City = *cityFlag
+	}
+
+	ds, err := permit.Read(*fileFlag, permit.Format(*formatFlag), mapping)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	rep := &report{
+		File:    *fileFlag,
+		Format:  ds.Format,
+		City:    mapping.City,
+		DryRun:  *dryRunFlag,
+		OnError: *onErrorFlag,
+		Rows:    ds.Len(),
+		Valid:   len(ds.Records),
+	}
+	for _, rej := range ds.Rejects {
+		rep.reject(stageValidate, rej)
+	}
+	for _, w := range ds.Warnings {
+		rep.warn(w)
+	}
+
+	rep.locate(ds.Records)
+	if len(rep.Unlocated) > 0 {
+		log.Warnf("%d rows could not be located, and won't match location queries", len(rep.Unlocated))
+	}
+
+	if len(ds.Warnings) > 0 {
+		log.Warnf("%d rows are imported without some of their values, see the report's warnings", len(ds.Warnings))
+	}
+
+	if len(ds.Rejects) > 0 {
+		log.Warnf("%d of %d rows are invalid", len(ds.Rejects), ds.Len())
+		if *onErrorFlag == onErrorFail {
+			finish(ds, rep)
+			log.Fatalf("aborting import, first invalid row %d: %s",
+				ds.Rejects[0].Row, ds.Rejects[0].Reason)
+		}
+	}
+
+	if *dryRunFlag {
+		finish(ds, rep)
+		log.Infof("dry run complete: %d valid, %d invalid rows\n", rep.Valid, len(rep.Rejected))
+		return
+	}
+
 	client := weaviate.New(config.Conf.Weaviate)
 
-	err = createSchema(client)
+	active, err := store.ReadActiveDataset(ctx, client)
 	if err != nil {
-		log.Fatalf("failed to create schema: %s", failure.WeaviateError(err))
+		log.Fatal(err)
 	}
 
-	batcher := client.Batch().ObjectsBatcher()
+	classes, err := prepareClasses(ctx, client, *versionFlag)
+	if err != nil {
+		log.Fatalf("failed to create schema: %s", err)
+	}
 
-	log.Infof("importing %d objects...\n", len(recs)-1)
-	var importCount int
+	rep.Version = *versionFlag
+	rep.Class = schema.VersionedClassName(*versionFlag)
+	rep.PreviousClass = active.Class
 
-	for i := 1; i < len(recs); i += batchSize {
-		for j := i; j < i+batchSize && j < len(recs); j++ {
-			addObjectToBatch(batcher, recs[j])
-			importCount++
+	existing, err := activeTrucks(ctx, client, classes, active)
+	if err != nil {
+		store.DropUnactivated(client, rep.Class)
+		log.Fatalf("failed to list existing objects: %s", err)
+	}
+
+	// the new version holds every truck, so rows are compared
+	// against the active version only to report what changed
+	var objs []*models.Object
+	created := make(map[string]bool)
+	incoming := make(map[string]bool, ds.Len())
+	byID := make(map[strfmt.UUID]permit.Record, len(ds.Records))
+	for _, rec := range ds.Records {
+		t := store.FromRecord(rec)
+		incoming[t.ID] = true
+
+		prev, ok := existing[t.ID]
+		switch {
+		case !ok:
+			created[t.ID] = true
+			rep.Created++
+		case !prev.Equal(&t):
+			rep.Updated++
+		default:
+			rep.Unchanged++
 		}
 
-		checkBatchInsertResult(batcher.Do(context.Background()))
-		log.Infof("objects imported: %d\n", importCount)
+		obj := store.NewObject(rep.Class, t)
+		objs = append(objs, obj)
+		byID[obj.ID] = rec
 	}
-}
 
-func readVectorFile() (records [][]string, err error) {
-	f, err := os.Open(csvPath)
-	if err != nil {
-		err = fmt.Errorf("failed to open csv: %s", err)
-		return
+	// an invalid row is kept out of the import, but
+	// its previously imported object is not vanished
+	rejected := make(map[string]bool, len(ds.Rejects))
+	for _, rej := range ds.Rejects {
+		if rej.LocationID != "" {
+			rejected[permit.Record{City: mapping.City, LocationID: rej.LocationID}.ID()] = true
+		}
 	}
-	defer f.Close()
 
-	r := csv.NewReader(f)
-	records, err = r.ReadAll()
-	if err != nil {
-		err = fmt.Errorf("failed to read csv: %s", err)
-		return
+	// other cities' trucks, and this city's trucks which aren't
+	// in the dataset, are carried over from the active version
+	for id, t := range existing {
+		if incoming[id] {
+			continue
+		}
+
+		if t.City == mapping.City && !rejected[id] && *syncFlag {
+			if !*tombstoneFlag {
+				rep.Removed++
+				continue
+			}
+			if t.Status != permit.StatusRemoved {
+				rep.Removed++
+				t = tombstone(t)
+			}
+		}
+
+		objs = append(objs, store.NewObject(rep.Class, t))
+		rep.CarriedOver++
 	}
 
-	return
-}
+	l := loader.New(client, loader.Config{
+		BatchSize:  *batchSizeFlag,
+		Workers:    *workersFlag,
+		MaxRetries: *retriesFlag,
+	})
 
-func createSchema(client *weaviate.Client) (err error) {
-	return client.Schema().
-		ClassCreator().
-		WithClass(schema.New()).
-		Do(context.Background())
-}
+	log.Infof("importing %d objects into %s...\n", len(objs), rep.Class)
+	written, err := l.Load(ctx, objs, func(f loader.Failure) error {
+		rec, ok := byID[f.Object.ID]
+		if !ok {
+			rep.CarriedOver--
+			return fmt.Errorf("failed to carry over object %s: %s", f.Object.ID, f.Reason)
+		}
+
+		if created[rec.ID()] {
+			rep.Created--
+		} else {
+			rep.Updated--
+		}
+		rep.reject(stageImport, permit.Rejection{
+			Row:        rec.Row,
+			LocationID: rec.LocationID,
+			Applicant:  rec.Applicant,
+			Reason:     f.Reason,
+		})
+
+		if *onErrorFlag == onErrorFail {
+			return fmt.Errorf("failed to create row %d: %s", rec.Row, f.Reason)
+		}
+		return nil
+	})
 
-func addObjectToBatch(batcher *batch.ObjectsBatcher, rec []string) {
-	parsedLat, err := parseFloat32(rec[LatitudeCol])
 	if err != nil {
-		log.Fatalf("failed to parse latitude for applicant %s: %s", rec[ApplicantCol], err)
+		finish(ds, rep)
+		store.DropUnactivated(client, rep.Class)
+		if errors.Is(err, context.Canceled) {
+			log.Fatalf("import interrupted, %s is still active", active.Class)
+		}
+		log.Fatalf("aborting import, %s is still active: %s", active.Class, err)
 	}
 
-	parsedLong, err := parseFloat32(rec[LongitudeCol])
+	// the new version is only activated once every object written
+	// can be read back. On a mismatch it is kept for inspection
+	count, err := store.CountObjects(ctx, client, rep.Class)
 	if err != nil {
-		log.Fatalf("failed to parse longitude for applicant %s: %s", rec[ApplicantCol], err)
-	}
-
-	batcher.WithObject(&models.Object{
-		Class: schema.ClassName,
-		Properties: map[string]interface{}{
-			schema.PropName:         rec[ApplicantCol],
-			schema.PropFacilityType: rec[FacilityTypeCol],
-			schema.PropFoodItems:    rec[FoodItemsCol],
-			schema.PropLocation: &models.GeoCoordinates{
-				Latitude:  &parsedLat,
-				Longitude: &parsedLong,
-			},
-		},
-	})
-}
+		finish(ds, rep)
+		log.Fatalf("failed to validate %s, %s is still active: %s", rep.Class, active.Class, err)
+	}
+	if count != written {
+		finish(ds, rep)
+		log.Fatalf("%s holds %d objects, expected %d. %s is still active, and %s is kept for inspection",
+			rep.Class, count, written, active.Class, rep.Class)
+	}
 
-func parseFloat32(in string) (parsed float32, err error) {
-	p, err := strconv.ParseFloat(in, 32)
+	// trucks written through the admin API since the active version was
+	// read would be lost by the cutover, so the import must be rerun.
+	// A write which reads the active version after this check notices
+	// the cutover when it's done, and fails to be retried. Two servers
+	// may count their writes the same, but each updates the pointer
+	latest, err := store.ReadActiveDataset(ctx, client)
 	if err != nil {
-		err = fmt.Errorf("failed to parse float32: %s", in)
-		return
+		finish(ds, rep)
+		store.DropUnactivated(client, rep.Class)
+		log.Fatalf("failed to check %s before activating %s: %s", active.Class, rep.Class, err)
+	}
+	if latest.Class != active.Class || latest.Writes != active.Writes || latest.UpdatedAt != active.UpdatedAt {
+		finish(ds, rep)
+		store.DropUnactivated(client, rep.Class)
+		log.Fatalf("%s was written to during the import, and is still active. Rerun the import", latest.Class)
 	}
 
-	parsed = float32(p)
-	return
-}
+	if err := store.Activate(ctx, client, rep.Class, rep.Version, count); err != nil {
+		finish(ds, rep)
+		log.Fatalf("failed to activate %s, %s is still active: %s", rep.Class, active.Class, err)
+	}
+	log.Infof("activated %s with %d objects, replacing %s\n", rep.Class, count, active.Class)
 
-func checkBatchInsertResult(created []models.ObjectsGetResponse, err error) {
-	if err != nil {
-		log.Fatal(failure.WeaviateError(err))
+	if _, ok := classes[active.Class]; ok && *dropOldFlag {
+		if err := store.DropClass(ctx, client, active.Class); err != nil {
+			log.Errorf("failed to drop %s: %s", active.Class, err)
+		} else {
+			rep.DroppedPrevious = true
+			log.Infof("dropped %s\n", active.Class)
+		}
 	}
 
-	// each created object can contain its own error
-	// as well. iterate through and check each one
-	for _, c := range created {
-		if c.Result != nil {
-			if c.Result.Errors != nil && c.Result.Errors.Error != nil {
-				log.Fatalf("failed to create obj: %+v, with status: %v",
-					c.Result.Errors.Error[0], c.Result.Status)
-			}
+	finish(ds, rep)
+	log.Infof("import complete: %d created, %d updated, %d unchanged, %d removed, %d carried over, %d rejected\n",
+		rep.Created, rep.Updated, rep.Unchanged, rep.Removed, rep.CarriedOver, len(rep.Rejected))
+}
+
+// finish writes the report, and under the quarantine
+// policy, the rejected rows
+func finish(ds *permit.Dataset, rep *report) {
+	if *onErrorFlag == onErrorQuarantine {
+		if err := writeRejects(*rejectsFlag, ds, rep.Rejected); err != nil {
+			log.Error(err)
+		} else if len(rep.Rejected) > 0 {
+			log.Infof("%d rejected rows written to %s\n", len(rep.Rejected), *rejectsFlag)
 		}
 	}
+
+	if err := rep.write(*reportFlag); err != nil {
+		log.Error(err)
+	}
 }
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/parkerduckworth/lonchera/permit"
+)
+
+// Policies for handling rows which are rejected, either because they
+// are invalid, or because Weaviate refused to store them
+const (
+	// onErrorSkip leaves rejected rows out of the import
+	onErrorSkip = "skip"
+
+	// onErrorFail stops the import at the first rejected row
+	onErrorFail = "fail"
+
+	// onErrorQuarantine skips rejected rows, and
+	// copies them to the rejects CSV for fixing
+	onErrorQuarantine = "quarantine"
+)
+
+// Stages at which a row may be rejected
+const (
+	stageValidate = "validate"
+	stageImport   = "import"
+)
+
+// report is the machine-readable outcome of an import
+type report struct {
+	File    string        `json:"file"`
+	Format  permit.Format `json:"format"`
+	City    string        `json:"city"`
+	DryRun  bool          `json:"dryRun"`
+	OnError string        `json:"onError"`
+
+	// Version is the dataset version imported, into Class. PreviousClass
+	// was active before the import, and DroppedPrevious is set once it
+	// has been dropped
+	Version         string `json:"version,omitempty"`
+	Class           string `json:"class,omitempty"`
+	PreviousClass   string `json:"previousClass,omitempty"`
+	DroppedPrevious bool   `json:"droppedPrevious,omitempty"`
+
+	// Rows counts every row in the dataset, and
+	// Valid those which passed validation
+	Rows  int `json:"rows"`
+	Valid int `json:"valid"`
+
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+
+	// CarriedOver counts the objects copied from the previous version,
+	// either of other cities, or of this city's rows not in the dataset
+	CarriedOver int `json:"carriedOver"`
+
+	// Converted counts the rows located from their State Plane x/y,
+	// and Unlocated lists the valid rows which could not be located.
+	// These are imported, but never match a location query
+	Converted int         `json:"statePlaneConverted"`
+	Unlocated []rejection `json:"unlocated"`
+
+	Rejected []rejection `json:"rejected"`
+
+	// Warnings lists the valid rows some of whose values were ignored,
+	// such as hours which can't be parsed. These are imported without them
+	Warnings []rejection `json:"warnings"`
+}
+
+// rejection is a row which was left out of the import, or flagged, and why
+type rejection struct {
+	Row        int    `json:"row"`
+	LocationID string `json:"locationId,omitempty"`
+	Applicant  string `json:"applicant,omitempty"`
+	Stage      string `json:"stage"`
+	Reason     string `json:"reason"`
+}
+
+func (r *report) reject(stage string, rej permit.Rejection) {
+	r.Rejected = append(r.Rejected, rejection{
+		Row:        rej.Row,
+		LocationID: rej.LocationID,
+		Applicant:  rej.Applicant,
+		Stage:      stage,
+		Reason:     rej.Reason,
+	})
+}
+
+// warn flags a row which is imported without some of its values
+func (r *report) warn(rej permit.Rejection) {
+	r.Warnings = append(r.Warnings, rejection{
+		Row:        rej.Row,
+		LocationID: rej.LocationID,
+		Applicant:  rej.Applicant,
+		Stage:      stageValidate,
+		Reason:     rej.Reason,
+	})
+}
+
+// locate counts how each valid record was located
+func (r *report) locate(recs []permit.Record) {
+	for _, rec := range recs {
+		switch rec.LocationSource {
+		case permit.LocationSourceStatePlane:
+			r.Converted++
+		case "":
+			reason := "no latitude/longitude or state plane x/y"
+			if rec.Address != "" {
+				reason += ", address " + rec.Address
+			}
+
+			r.Unlocated = append(r.Unlocated, rejection{
+				Row:        rec.Row,
+				LocationID: rec.LocationID,
+				Applicant:  rec.Applicant,
+				Stage:      stageValidate,
+				Reason:     reason,
+			})
+		}
+	}
+}
+
+// write writes the report as JSON to path, or to stdout if path is empty
+func (r *report) write(path string) error {
+	if r.Rejected == nil {
+		r.Rejected = []rejection{}
+	}
+	if r.Unlocated == nil {
+		r.Unlocated = []rejection{}
+	}
+	if r.Warnings == nil {
+		r.Warnings = []rejection{}
+	}
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %s", err)
+	}
+	b = append(b, '\n')
+
+	if path == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %s", err)
+	}
+	return nil
+}
+
+// writeRejects copies the raw values of every rejected row to a CSV at
+// path, under the dataset's own header, adding the reason for each
+func writeRejects(path string, ds *permit.Dataset, rejected []rejection) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create rejects file: %s", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(append(append([]string{}, ds.Header...), "reject_reason")); err != nil {
+		return fmt.Errorf("failed to write rejects file: %s", err)
+	}
+
+	for _, rej := range rejected {
+		row := append(append([]string{}, ds.Row(rej.Row)...), rej.Reason)
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed to write rejects file: %s", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write rejects file: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/loader"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+	"github.com/parkerduckworth/lonchera/snapshot"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+)
+
+func init() {
+	config.Setup()
+	log.Setup()
+}
+
+var (
+	fileFlag = flag.String("file", "",
+		"JSONL snapshot to restore, as written by the export command")
+	versionFlag = flag.String("version", time.Now().UTC().Format("20060102150405"),
+		"version the snapshot is restored as, naming its class")
+	dropOldFlag = flag.Bool("drop-old", false,
+		"drop the previously active class once the restored version is activated")
+	batchSizeFlag = flag.Int("batch-size", loader.DefaultConfig().BatchSize,
+		"number of objects written in each batch")
+	workersFlag = flag.Int("workers", loader.DefaultConfig().Workers,
+		"number of batches written concurrently")
+	retriesFlag = flag.Int("retries", loader.DefaultConfig().MaxRetries,
+		"number of times a batch is retried after a transient Weaviate error")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *fileFlag == "" {
+		log.Fatal("-file is required")
+	}
+
+	if !schema.ValidVersion(*versionFlag) {
+		log.Fatalf("invalid -version %q, only letters, digits and underscores are allowed", *versionFlag)
+	}
+
+	f, err := os.Open(*fileFlag)
+	if err != nil {
+		log.Fatalf("failed to open snapshot: %s", err)
+	}
+
+	objs, err := snapshot.ReadJSONL(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var vectors int
+	for _, obj := range objs {
+		if len(obj.Vector) > 0 {
+			vectors++
+		}
+	}
+	if vectors < len(objs) {
+		log.Warnf("%d of %d objects have no vector, and will be vectorized", len(objs)-vectors, len(objs))
+	}
+
+	client := weaviate.New(config.Conf.Weaviate)
+
+	active, err := store.ReadActiveDataset(ctx, client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	previous, err := store.GetClass(ctx, client, active.Class)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	class, err := store.CreateVersionedClass(ctx, client, *versionFlag)
+	if err != nil {
+		log.Fatalf("failed to create schema: %s", err)
+	}
+
+	// objects keep their original IDs, so that links
+	// to trucks survive the move between environments
+	for _, obj := range objs {
+		obj.Class = class
+	}
+
+	l := loader.New(client, loader.Config{
+		BatchSize:  *batchSizeFlag,
+		Workers:    *workersFlag,
+		MaxRetries: *retriesFlag,
+	})
+
+	// a snapshot is restored whole, or not at all
+	log.Infof("restoring %d objects into %s...\n", len(objs), class)
+	written, err := l.Load(ctx, objs, func(f loader.Failure) error {
+		return fmt.Errorf("failed to restore object %s: %s", f.Object.ID, f.Reason)
+	})
+
+	if err != nil {
+		store.DropUnactivated(client, class)
+		if errors.Is(err, context.Canceled) {
+			log.Fatalf("restore interrupted, %s is still active", active.Class)
+		}
+		log.Fatalf("aborting restore, %s is still active: %s", active.Class, err)
+	}
+
+	count, err := store.CountObjects(ctx, client, class)
+	if err != nil {
+		log.Fatalf("failed to validate %s, %s is still active: %s", class, active.Class, err)
+	}
+	if count != written {
+		log.Fatalf("%s holds %d objects, expected %d. %s is still active, and %s is kept for inspection",
+			class, count, written, active.Class, class)
+	}
+
+	if err := store.Activate(ctx, client, class, *versionFlag, count); err != nil {
+		log.Fatalf("failed to activate %s, %s is still active: %s", class, active.Class, err)
+	}
+	log.Infof("activated %s with %d objects, replacing %s\n", class, count, active.Class)
+
+	if previous != nil && *dropOldFlag {
+		if err := store.DropClass(ctx, client, active.Class); err != nil {
+			log.Errorf("failed to drop %s: %s", active.Class, err)
+		} else {
+			log.Infof("dropped %s\n", active.Class)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+func init() {
+	config.Setup()
+	log.Setup()
+}
+
+var (
+	classFlag = flag.String("class", "",
+		"FoodTruck class to operate on (default: the active class)")
+	yesFlag = flag.Bool("yes", false,
+		"confirm drop, which deletes the class and every object in it")
+)
+
+const usage = `Usage: go run ./cmd/schema [flags] <command>
+
+Commands:
+  create    create the class, and the dataset pointer class
+  describe  print the active dataset, and the class's properties
+  diff      compare the class against the current schema
+  migrate   add the properties the class lacks, and record the schema version
+  drop      delete the class and every object in it, requires -yes
+
+Flags:
+`
+
+// commands run against the target class. active is the dataset
+// being served, and class the target, which is nil if it doesn't exist
+var commands = map[string]func(ctx context.Context, client *weaviate.Client,
+	active store.Dataset, name string, class *models.Class) error{
+
+	"create":   create,
+	"describe": describe,
+	"diff":     diff,
+	"migrate":  migrate,
+	"drop":     drop,
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// flags are accepted on either side of the command
+	command := flag.Arg(0)
+	if flag.NArg() > 0 {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	run, ok := commands[command]
+	if flag.NArg() > 0 || !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	client := weaviate.New(config.Conf.Weaviate)
+
+	active, err := store.ReadActiveDataset(ctx, client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := active.Class
+	if *classFlag != "" {
+		name = *classFlag
+	}
+
+	class, err := store.GetClass(ctx, client, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := run(ctx, client, active, name, class); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// create creates the class from schema.New, unless it already
+// exists and matches
+func create(ctx context.Context, client *weaviate.Client, active store.Dataset, name string, class *models.Class) error {
+	if err := store.EnsureDatasetClass(ctx, client); err != nil {
+		return err
+	}
+
+	if class != nil {
+		if diffs := schema.Diff(class); len(diffs) > 0 {
+			return fmt.Errorf("existing %s class does not match: %s", name, strings.Join(diffs, "; "))
+		}
+
+		log.Infof("%s class already exists, skipping creation\n", name)
+		return nil
+	}
+
+	want := schema.New()
+	want.Class = name
+
+	if err := client.Schema().ClassCreator().WithClass(want).Do(ctx); err != nil {
+		return failure.WeaviateError(err)
+	}
+
+	log.Infof("created %s class\n", name)
+	return nil
+}
+
+// describe prints the active dataset, then each property of the class
+func describe(ctx context.Context, client *weaviate.Client, active store.Dataset, name string, class *models.Class) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "active class:\t%s\n", active.Class)
+	if active.Version != "" {
+		fmt.Fprintf(w, "version:\t%s\n", active.Version)
+		fmt.Fprintf(w, "objects:\t%d\n", active.Objects)
+		fmt.Fprintf(w, "activated at:\t%s\n", active.ActivatedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	fmt.Fprintf(w, "schema version:\t%s\n", schemaVersion(active))
+	fmt.Fprintf(w, "server schema version:\t%d\n", schema.Version)
+	fmt.Fprintln(w)
+
+	if class == nil {
+		fmt.Fprintf(w, "class %s does not exist\n", name)
+		return w.Flush()
+	}
+
+	fmt.Fprintf(w, "class %s, vectorizer %s\n", class.Class, class.Vectorizer)
+	fmt.Fprintln(w, "PROPERTY\tTYPE\tDESCRIPTION")
+	for _, p := range class.Properties {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, strings.Join(p.DataType, ","), p.Description)
+	}
+
+	return w.Flush()
+}
+
+// diff prints each difference between the class and schema.New,
+// failing if there are any
+func diff(ctx context.Context, client *weaviate.Client, active store.Dataset, name string, class *models.Class) error {
+	if class == nil {
+		return fmt.Errorf("class %s does not exist", name)
+	}
+
+	diffs := schema.Diff(class)
+	if name == active.Class && active.SchemaVersion != 0 && active.SchemaVersion != schema.Version {
+		diffs = append(diffs, fmt.Sprintf("schema version is %d, want %d", active.SchemaVersion, schema.Version))
+	}
+
+	if len(diffs) == 0 {
+		fmt.Printf("%s matches schema version %d\n", name, schema.Version)
+		return nil
+	}
+
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	return fmt.Errorf("%s does not match schema version %d", name, schema.Version)
+}
+
+// migrate adds the properties the class lacks, then records the current
+// schema version if the class is active. A property whose data type has
+// changed can't be migrated in place, the dataset must be reimported
+func migrate(ctx context.Context, client *weaviate.Client, active store.Dataset, name string, class *models.Class) error {
+	if class == nil {
+		return fmt.Errorf("class %s does not exist", name)
+	}
+
+	if name == active.Class && active.SchemaVersion > schema.Version {
+		return fmt.Errorf("%s has schema version %d, newer than %d", name, active.SchemaVersion, schema.Version)
+	}
+
+	if err := store.EnsureDatasetClass(ctx, client); err != nil {
+		return err
+	}
+
+	added, err := store.AddProperties(ctx, client, class, schema.New())
+	for _, p := range added {
+		log.Infof("added property %s to %s\n", p, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %s", name, err)
+	}
+
+	class, err = store.GetClass(ctx, client, name)
+	if err != nil {
+		return err
+	}
+	if diffs := schema.Diff(class); len(diffs) > 0 {
+		return fmt.Errorf("%s can't be migrated in place, reimport the dataset: %s",
+			name, strings.Join(diffs, "; "))
+	}
+
+	// the unversioned class has no pointer to record the version
+	// with. It is checked against the schema's properties instead
+	if name == active.Class && active.Version != "" {
+		err = client.Data().Updater().
+			WithClassName(schema.DatasetClassName).
+			WithID(schema.DatasetID).
+			WithProperties(map[string]interface{}{
+				schema.PropSchemaVersion: schema.Version,
+			}).
+			WithMerge().
+			Do(ctx)
+
+		if err != nil {
+			return fmt.Errorf("failed to record schema version: %s", failure.WeaviateError(err))
+		}
+	}
+
+	log.Infof("%s is at schema version %d\n", name, schema.Version)
+	return nil
+}
+
+// drop deletes the class. Dropping the active class also deletes the
+// dataset pointer, so that the service falls back to the unversioned
+// FoodTruck class rather than pointing at a class which is gone
+func drop(ctx context.Context, client *weaviate.Client, active store.Dataset, name string, class *models.Class) error {
+	if class == nil {
+		return fmt.Errorf("class %s does not exist", name)
+	}
+
+	if !*yesFlag {
+		return fmt.Errorf("dropping %s deletes every object in it, rerun with -yes to confirm", name)
+	}
+
+	if err := client.Schema().ClassDeleter().WithClassName(name).Do(ctx); err != nil {
+		return failure.WeaviateError(err)
+	}
+	log.Infof("dropped %s\n", name)
+
+	if name == active.Class && active.Version != "" {
+		err := client.Data().Deleter().WithID(schema.DatasetID).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to deactivate %s: %s", name, failure.WeaviateError(err))
+		}
+		log.Infof("%s was active, no dataset is active now\n", name)
+	}
+
+	return nil
+}
+
+func schemaVersion(ds store.Dataset) string {
+	if ds.SchemaVersion == 0 {
+		return "unrecorded"
+	}
+	return fmt.Sprint(ds.SchemaVersion)
+}
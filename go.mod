@@ -4,6 +4,7 @@ go 1.17
 
 require (
 	github.com/gin-gonic/gin v1.7.7
+	github.com/go-openapi/strfmt v0.21.2
 	github.com/pkg/errors v0.9.1
 	github.com/semi-technologies/weaviate v1.13.1
 	github.com/semi-technologies/weaviate-go-client/v4 v4.0.0
@@ -23,7 +24,6 @@ require (
 	github.com/go-openapi/jsonreference v0.19.6 // indirect
 	github.com/go-openapi/loads v0.21.1 // indirect
 	github.com/go-openapi/spec v0.20.4 // indirect
-	github.com/go-openapi/strfmt v0.21.2 // indirect
 	github.com/go-openapi/swag v0.21.1 // indirect
 	github.com/go-openapi/validate v0.21.0 // indirect
 	github.com/go-playground/locales v0.13.0 // indirect
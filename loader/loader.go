@@ -0,0 +1,300 @@
+// Package loader writes objects to Weaviate in batches, submitted concurrently
+// by a bounded pool of workers. Batches which fail with a transient error are
+// retried with exponential backoff, and progress is logged while loading. It
+// is used by the importer, but knows nothing of food trucks, so it may load
+// objects of any class.
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/fault"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+// maxBackoff caps the delay between retries of a batch
+const maxBackoff = 30 * time.Second
+
+// Config tunes how objects are loaded
+type Config struct {
+	// BatchSize is the number of objects sent in each request
+	BatchSize int
+
+	// Workers is the number of batches submitted at once
+	Workers int
+
+	// MaxRetries is the number of times a batch is retried after a
+	// transient error, and Backoff the delay before the first retry,
+	// which doubles with each attempt
+	MaxRetries int
+	Backoff    time.Duration
+
+	// ProgressInterval is how often progress is logged
+	ProgressInterval time.Duration
+}
+
+// DefaultConfig returns the config used for any unset field
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:        50,
+		Workers:          4,
+		MaxRetries:       5,
+		Backoff:          500 * time.Millisecond,
+		ProgressInterval: 5 * time.Second,
+	}
+}
+
+// Failure is an object which Weaviate refused to store, and why
+type Failure struct {
+	Object *models.Object
+	Reason string
+}
+
+// Loader writes objects to Weaviate
+type Loader struct {
+	client *weaviate.Client
+	config Config
+}
+
+// New returns a Loader writing through client. A zero BatchSize,
+// Workers, Backoff or ProgressInterval is replaced by DefaultConfig's
+func New(client *weaviate.Client, config Config) *Loader {
+	def := DefaultConfig()
+	if config.BatchSize < 1 {
+		config.BatchSize = def.BatchSize
+	}
+	if config.Workers < 1 {
+		config.Workers = def.Workers
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = def.Backoff
+	}
+	if config.ProgressInterval <= 0 {
+		config.ProgressInterval = def.ProgressInterval
+	}
+
+	return &Loader{client: client, config: config}
+}
+
+// Load writes every object, returning the number written. Each object
+// refused by Weaviate is passed to onFailure, which is never called
+// concurrently. If onFailure returns an error, or a batch fails for
+// good, loading stops and that error is returned. Loading also stops
+// when ctx is cancelled, returning the context's error
+func (l *Loader) Load(ctx context.Context, objs []*models.Object,
+	onFailure func(Failure) error) (int, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	stop := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	prog := newProgress(len(objs))
+	done := make(chan struct{})
+	go prog.run(l.config.ProgressInterval, done)
+
+	batches := make(chan []*models.Object)
+	var wg sync.WaitGroup
+	for i := 0; i < l.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				failed, err := l.submit(ctx, batch)
+				if err != nil {
+					stop(err)
+					continue
+				}
+
+				for _, obj := range batch {
+					reason, ok := failed[obj.ID]
+					if !ok {
+						prog.add(1)
+						continue
+					}
+
+					mu.Lock()
+					err := onFailure(Failure{Object: obj, Reason: reason})
+					mu.Unlock()
+					if err != nil {
+						stop(err)
+					}
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < len(objs); i += l.config.BatchSize {
+		end := i + l.config.BatchSize
+		if end > len(objs) {
+			end = len(objs)
+		}
+
+		select {
+		case batches <- objs[i:end]:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	close(done)
+	prog.log()
+
+	if firstErr != nil {
+		return prog.written(), firstErr
+	}
+	return prog.written(), ctx.Err()
+}
+
+// submit writes a single batch, retrying transient errors. It
+// returns the reason each refused object failed, by ID
+func (l *Loader) submit(ctx context.Context, batch []*models.Object) (map[strfmt.UUID]string, error) {
+	backoff := l.config.Backoff
+	for attempt := 0; ; attempt++ {
+		batcher := l.client.Batch().ObjectsBatcher()
+		for _, obj := range batch {
+			batcher.WithObject(obj)
+		}
+
+		created, err := batcher.Do(ctx)
+		if err == nil {
+			return failures(created), nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if attempt >= l.config.MaxRetries || !transient(err) {
+			return nil, fmt.Errorf("failed to write batch: %s", failure.WeaviateError(err))
+		}
+
+		log.Warnf("batch failed, retrying in %s: %s", backoff, failure.WeaviateError(err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// nextBackoff doubles the delay before a retry, up to maxBackoff
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// failures collects the error of each object which could not be
+// created, as each created object can contain its own error
+func failures(created []models.ObjectsGetResponse) map[strfmt.UUID]string {
+	failed := make(map[strfmt.UUID]string)
+	for _, c := range created {
+		if c.Result != nil && c.Result.Errors != nil && len(c.Result.Errors.Error) > 0 {
+			failed[c.ID] = c.Result.Errors.Error[0].Message
+		}
+	}
+	return failed
+}
+
+// transient reports whether a batch error may succeed if retried:
+// connection errors, rate limiting, and server errors
+func transient(err error) bool {
+	var werr *fault.WeaviateClientError
+	if !errors.As(err, &werr) {
+		return false
+	}
+
+	if !werr.IsUnexpectedStatusCode {
+		return true
+	}
+
+	return werr.StatusCode == http.StatusTooManyRequests ||
+		werr.StatusCode >= http.StatusInternalServerError
+}
+
+// progress tracks the objects written, to log throughput and ETA
+type progress struct {
+	total int
+	done  int64
+	start time.Time
+}
+
+func newProgress(total int) *progress {
+	return &progress{total: total, start: time.Now()}
+}
+
+func (p *progress) add(n int) {
+	atomic.AddInt64(&p.done, int64(n))
+}
+
+func (p *progress) written() int {
+	return int(atomic.LoadInt64(&p.done))
+}
+
+// run logs progress every interval until done is closed
+func (p *progress) run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.log()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (p *progress) log() {
+	written := p.written()
+	elapsed := time.Since(p.start)
+
+	var rate float64
+	if secs := elapsed.Seconds(); secs > 0 {
+		rate = float64(written) / secs
+	}
+
+	eta := "unknown"
+	if rate > 0 {
+		remaining := time.Duration(float64(p.total-written) / rate * float64(time.Second))
+		eta = remaining.Round(time.Second).String()
+	}
+
+	log.Infof("objects written: %d/%d (%.1f/s), eta %s\n", written, p.total, rate, eta)
+}
@@ -0,0 +1,254 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/fault"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+func TestMain(m *testing.M) {
+	config.Conf.Logger.Level = "ERROR"
+	log.Setup()
+
+	os.Exit(m.Run())
+}
+
+// batchServer stands in for Weaviate's batch endpoint. Each request is
+// answered by respond, given its 1-based number, with a status code.
+// Objects of a successful request are echoed back, with an error for
+// those whose ID is in refuse
+type batchServer struct {
+	*httptest.Server
+	requests int32
+}
+
+func newBatchServer(t *testing.T, respond func(n int, w http.ResponseWriter, r *http.Request) int, refuse ...strfmt.UUID) *batchServer {
+	s := &batchServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/batch/objects" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		n := int(atomic.AddInt32(&s.requests, 1))
+		if code := respond(n, w, r); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+
+		var body struct {
+			Objects []*models.Object `json:"objects"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode batch: %s", err)
+		}
+
+		resp := make([]models.ObjectsGetResponse, len(body.Objects))
+		for i, obj := range body.Objects {
+			resp[i].Object = *obj
+			for _, id := range refuse {
+				if obj.ID == id {
+					resp[i].Result = &models.ObjectsGetResponseAO2Result{
+						Errors: &models.ErrorResponse{
+							Error: []*models.ErrorResponseErrorItems0{{Message: "invalid object"}},
+						},
+					}
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *batchServer) loader(config Config) *Loader {
+	client := weaviate.New(weaviate.Config{
+		Host:   strings.TrimPrefix(s.URL, "http://"),
+		Scheme: "http",
+	})
+
+	config.Workers = 1
+	config.Backoff = time.Millisecond
+	return New(client, config)
+}
+
+func objects(n int) []*models.Object {
+	objs := make([]*models.Object, n)
+	for i := range objs {
+		objs[i] = &models.Object{
+			Class: "FoodTruck",
+			ID:    strfmt.UUID(fmt.Sprintf("00000000-0000-0000-0000-%012d", i)),
+		}
+	}
+	return objs
+}
+
+func noFailures(t *testing.T) func(Failure) error {
+	return func(f Failure) error {
+		t.Errorf("unexpected failure of %s: %s", f.Object.ID, f.Reason)
+		return nil
+	}
+}
+
+func TestLoadRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		codes      []int
+		maxRetries int
+		written    int
+		requests   int
+		err        bool
+	}{
+		{"transient errors", []int{http.StatusTooManyRequests, http.StatusInternalServerError}, 5, 10, 3, false},
+		{"retries exhausted", []int{503, 503, 503, 503}, 2, 0, 3, true},
+		{"not transient", []int{http.StatusUnprocessableEntity}, 5, 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newBatchServer(t, func(n int, w http.ResponseWriter, r *http.Request) int {
+				if n <= len(tt.codes) {
+					return tt.codes[n-1]
+				}
+				return http.StatusOK
+			})
+
+			written, err := s.loader(Config{BatchSize: 10, MaxRetries: tt.maxRetries}).
+				Load(context.Background(), objects(10), noFailures(t))
+
+			if (err != nil) != tt.err {
+				t.Errorf("error = %v, want an error: %t", err, tt.err)
+			}
+			if written != tt.written {
+				t.Errorf("written = %d, want %d", written, tt.written)
+			}
+			if got := int(atomic.LoadInt32(&s.requests)); got != tt.requests {
+				t.Errorf("requests = %d, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestLoadFailures(t *testing.T) {
+	objs := objects(10)
+	s := newBatchServer(t, func(int, http.ResponseWriter, *http.Request) int {
+		return http.StatusOK
+	}, objs[2].ID, objs[7].ID)
+
+	var failed []strfmt.UUID
+	written, err := s.loader(Config{BatchSize: 4}).Load(context.Background(), objs, func(f Failure) error {
+		if f.Reason != "invalid object" {
+			t.Errorf("reason = %q, want the object's error", f.Reason)
+		}
+		failed = append(failed, f.Object.ID)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	if written != 8 {
+		t.Errorf("written = %d, want 8", written)
+	}
+	if len(failed) != 2 || failed[0] != objs[2].ID || failed[1] != objs[7].ID {
+		t.Errorf("failed = %v, want %s and %s", failed, objs[2].ID, objs[7].ID)
+	}
+}
+
+func TestLoadStopsOnFailureError(t *testing.T) {
+	objs := objects(10)
+	s := newBatchServer(t, func(int, http.ResponseWriter, *http.Request) int {
+		return http.StatusOK
+	}, objs[1].ID)
+
+	stop := errors.New("too many failures")
+	written, err := s.loader(Config{BatchSize: 4}).Load(context.Background(), objs, func(Failure) error {
+		return stop
+	})
+
+	// the rest of the first batch is written, and no other batch is sent
+	if !errors.Is(err, stop) {
+		t.Errorf("error = %v, want %v", err, stop)
+	}
+	if written != 3 {
+		t.Errorf("written = %d, want 3", written)
+	}
+	if got := atomic.LoadInt32(&s.requests); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestLoadCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the second batch is cancelled while it is being written, and
+	// fails with an error which would otherwise be retried
+	s := newBatchServer(t, func(n int, w http.ResponseWriter, r *http.Request) int {
+		if n == 2 {
+			cancel()
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+
+	written, err := s.loader(Config{BatchSize: 4, MaxRetries: 5}).Load(ctx, objects(10), noFailures(t))
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+	if written != 4 {
+		t.Errorf("written = %d, want 4", written)
+	}
+	if got := atomic.LoadInt32(&s.requests); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	backoff := 10 * time.Second
+	for _, want := range []time.Duration{20 * time.Second, maxBackoff, maxBackoff} {
+		if backoff = nextBackoff(backoff); backoff != want {
+			t.Errorf("backoff = %s, want %s", backoff, want)
+		}
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection error", &fault.WeaviateClientError{DerivedFromError: errors.New("connection refused")}, true},
+		{"rate limited", &fault.WeaviateClientError{IsUnexpectedStatusCode: true, StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", &fault.WeaviateClientError{IsUnexpectedStatusCode: true, StatusCode: http.StatusBadGateway}, true},
+		{"bad request", &fault.WeaviateClientError{IsUnexpectedStatusCode: true, StatusCode: http.StatusUnprocessableEntity}, false},
+		{"other error", errors.New("invalid object"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transient(fmt.Errorf("wrapped: %w", tt.err)); got != tt.want {
+				t.Errorf("transient = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
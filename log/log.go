@@ -15,6 +15,10 @@ import (
 
 var logger Logger
 
+// auditLogger logs Audit entries. It has a level of its own, so
+// that they are kept whatever the application's log level
+var auditLogger = logrus.New()
+
 type Logger struct {
 	internalLogger *logrus.Entry
 }
@@ -67,6 +71,13 @@ func Fatalf(format string, args ...interface{}) {
 	logger.internalLogger.Fatalf(format, args...)
 }
 
+// Audit records a change to the trucks being served, such as one made
+// through the admin API. Entries carry audit=true along with fields,
+// so they can be picked out of the application log
+func Audit(fields map[string]interface{}, msg string) {
+	auditLogger.WithField("audit", true).WithFields(fields).Info(msg)
+}
+
 func (logger *Logger) SetLevel(level string) {
 	switch level {
 	case "TRACE":
@@ -105,6 +116,7 @@ func Setup() {
 		},
 	}
 	logrus.SetFormatter(formatter)
+	auditLogger.SetFormatter(formatter)
 	lgr := logrus.WithContext(context.Background())
 
 	logger = Logger{
@@ -1,14 +1,18 @@
 package main
 
 import (
+	_ "time/tzdata" // the release image has no system time zone database
+
 	"github.com/parkerduckworth/lonchera/app"
 	"github.com/parkerduckworth/lonchera/app/config"
 	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/recommender"
 )
 
 func init() {
 	config.Setup()
 	log.Setup()
+	recommender.Setup()
 }
 
 func main() {
@@ -0,0 +1,474 @@
+package permit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+)
+
+// Format is the encoding of a permit dataset file
+type Format string
+
+// Formats in which open data portals publish permit datasets
+const (
+	// FormatAuto detects the format from the file's extension and content
+	FormatAuto Format = ""
+
+	// FormatCSV is a CSV file whose first row is the header
+	FormatCSV Format = "csv"
+
+	// FormatJSON is an array of flat JSON objects, one per row, as
+	// returned by Socrata's SODA API. Nested objects are flattened,
+	// so {"location": {"latitude": 1}} is read as column location.latitude
+	FormatJSON Format = "json"
+
+	// FormatSODA is a Socrata rows.json export, whose column
+	// names are listed in meta.view.columns and rows in data
+	FormatSODA Format = "soda"
+
+	// FormatGeoJSON is a GeoJSON FeatureCollection, whose
+	// feature properties are read as columns
+	FormatGeoJSON Format = "geojson"
+)
+
+// utf8BOM is skipped when sniffing a file's format
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// table is a permit dataset decoded into text cells under a header,
+// whatever its format. For GeoJSON, points holds each row's point
+// geometry, which is nil for rows without one
+type table struct {
+	header []string
+	rows   [][]string
+	points []*point
+
+	// invalid holds, for each row, why its geometry can't be read,
+	// or is empty if it can. Rows with invalid geometries are rejected
+	invalid []string
+}
+
+type point struct {
+	lat float64
+	lng float64
+}
+
+// Dataset is the outcome of reading a permit dataset. Rows which
+// could not be read into a Record are rejected rather than failing
+// the whole read, so that callers may decide how to handle them
+type Dataset struct {
+	// Format is the format the dataset was read as
+	Format Format
+
+	// Header names the dataset's columns
+	Header []string
+
+	// Records holds every valid row, and Rejects every invalid one.
+	// Warnings flags the valid rows some of whose values were ignored
+	Records  []Record
+	Rejects  []Rejection
+	Warnings []Rejection
+
+	rows [][]string
+}
+
+// Rejection describes a row which could not be read, or only in part
+type Rejection struct {
+	// Row is the 1-based position of the row in the dataset,
+	// not counting the header
+	Row        int
+	LocationID string
+	Applicant  string
+	Reason     string
+}
+
+// Len returns the number of rows in the dataset, valid or not
+func (d *Dataset) Len() int {
+	return len(d.rows)
+}
+
+// Row returns the raw values of the 1-based row n
+func (d *Dataset) Row(n int) []string {
+	return d.rows[n-1]
+}
+
+// Read reads every row of the permit dataset found at path, in the given
+// format, locating columns through the mapping. If format is FormatAuto
+// the format is detected, and if m is nil, DefaultMapping is used. An
+// error is only returned if the dataset as a whole cannot be read
+func Read(path string, format Format, m *Mapping) (*Dataset, error) {
+	if m == nil {
+		m = DefaultMapping()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %s", err)
+	}
+
+	if format == FormatAuto {
+		format = DetectFormat(path, data)
+	}
+
+	var t *table
+	switch format {
+	case FormatCSV:
+		t, err = readCSVTable(data)
+	case FormatJSON:
+		t, err = readJSONTable(data)
+	case FormatSODA:
+		t, err = readSODATable(data)
+	case FormatGeoJSON:
+		t, err = readGeoJSONTable(data)
+	default:
+		return nil, fmt.Errorf("unknown dataset format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", format, err)
+	}
+
+	ds := &Dataset{Format: format}
+	if len(t.rows) == 0 {
+		return ds, nil
+	}
+
+	if t.points != nil {
+		t.setPoints(m)
+	}
+
+	cols, err := m.resolve(t.header)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Header = t.header
+	ds.rows = t.rows
+	ds.Records = make([]Record, 0, len(t.rows))
+
+	// the same location ID would be imported as the same object,
+	// so only the first row with a given ID is kept
+	seen := make(map[string]int, len(t.rows))
+	for i, row := range t.rows {
+		rec, warnings, err := m.parseRow(row, cols)
+		rec.Row = i + 1
+
+		if err == nil && t.invalid != nil && t.invalid[i] != "" {
+			err = errors.New(t.invalid[i])
+		}
+
+		if err == nil {
+			if first, ok := seen[rec.LocationID]; ok {
+				err = fmt.Errorf("duplicate location id, first seen on row %d", first)
+			} else {
+				seen[rec.LocationID] = rec.Row
+			}
+		}
+
+		if err != nil {
+			ds.Rejects = append(ds.Rejects, Rejection{
+				Row:        rec.Row,
+				LocationID: rec.LocationID,
+				Applicant:  rec.Applicant,
+				Reason:     err.Error(),
+			})
+			continue
+		}
+
+		ds.Records = append(ds.Records, rec)
+		for _, w := range warnings {
+			ds.Warnings = append(ds.Warnings, Rejection{
+				Row:        rec.Row,
+				LocationID: rec.LocationID,
+				Applicant:  rec.Applicant,
+				Reason:     w,
+			})
+		}
+	}
+
+	return ds, nil
+}
+
+// DetectFormat guesses the format of a dataset from its path's extension,
+// falling back to the shape of its content. Anything which is not JSON
+// is assumed to be CSV
+func DetectFormat(path string, data []byte) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".geojson":
+		return FormatGeoJSON
+	}
+
+	data = bytes.TrimPrefix(data, utf8BOM)
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 {
+		return FormatCSV
+	}
+
+	switch data[0] {
+	case '[':
+		return FormatJSON
+	case '{':
+		var probe struct {
+			Type string          `json:"type"`
+			Meta json.RawMessage `json:"meta"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return FormatJSON
+		}
+
+		switch {
+		case probe.Type == "FeatureCollection":
+			return FormatGeoJSON
+		case probe.Meta != nil:
+			return FormatSODA
+		}
+		return FormatJSON
+	}
+
+	return FormatCSV
+}
+
+func readCSVTable(data []byte) (*table, error) {
+	rows, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM))).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return &table{}, nil
+	}
+
+	return &table{header: rows[0], rows: rows[1:]}, nil
+}
+
+func readJSONTable(data []byte) (*table, error) {
+	var objs []map[string]interface{}
+	if err := decodeJSON(data, &objs); err != nil {
+		return nil, err
+	}
+
+	return objectsTable(objs), nil
+}
+
+func readSODATable(data []byte) (*table, error) {
+	var export struct {
+		Meta struct {
+			View struct {
+				Columns []struct {
+					Name string `json:"name"`
+				} `json:"columns"`
+			} `json:"view"`
+		} `json:"meta"`
+		Data [][]interface{} `json:"data"`
+	}
+	if err := decodeJSON(data, &export); err != nil {
+		return nil, err
+	}
+
+	t := &table{rows: make([][]string, len(export.Data))}
+	for _, col := range export.Meta.View.Columns {
+		t.header = append(t.header, col.Name)
+	}
+
+	for i, values := range export.Data {
+		row := make([]string, len(values))
+		for j, v := range values {
+			row[j] = cellText(v)
+		}
+		t.rows[i] = row
+	}
+
+	return t, nil
+}
+
+func readGeoJSONTable(data []byte) (*table, error) {
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry *struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := decodeJSON(data, &fc); err != nil {
+		return nil, err
+	}
+
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("expected a FeatureCollection, got %q", fc.Type)
+	}
+
+	objs := make([]map[string]interface{}, len(fc.Features))
+	points := make([]*point, len(fc.Features))
+	invalid := make([]string, len(fc.Features))
+	for i, f := range fc.Features {
+		objs[i] = f.Properties
+
+		// only points locate a row, and other geometries, whose
+		// coordinates are nested arrays, are ignored
+		g := f.Geometry
+		if g == nil || g.Type != "Point" {
+			continue
+		}
+
+		// GeoJSON positions are ordered longitude, latitude
+		var pos []json.Number
+		if err := decodeJSON(g.Coordinates, &pos); err != nil {
+			invalid[i] = fmt.Sprintf("invalid point coordinates: %s", err)
+			continue
+		}
+		if len(pos) < 2 {
+			continue
+		}
+
+		lng, err := pos[0].Float64()
+		if err != nil {
+			invalid[i] = fmt.Sprintf("invalid point longitude %q", pos[0])
+			continue
+		}
+		lat, err := pos[1].Float64()
+		if err != nil {
+			invalid[i] = fmt.Sprintf("invalid point latitude %q", pos[1])
+			continue
+		}
+		points[i] = &point{lat: lat, lng: lng}
+	}
+
+	t := objectsTable(objs)
+	t.points = points
+	t.invalid = invalid
+	return t, nil
+}
+
+// decodeJSON unmarshals data, keeping numbers in their original text
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// objectsTable flattens JSON objects into a table. The header holds
+// every key found, in order of first appearance, with the keys
+// of each object taken in sorted order
+func objectsTable(objs []map[string]interface{}) *table {
+	t := &table{rows: make([][]string, len(objs))}
+	index := make(map[string]int)
+
+	for i, obj := range objs {
+		flat := make(map[string]string)
+		flatten("", obj, flat)
+
+		keys := make([]string, 0, len(flat))
+		for k := range flat {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if _, ok := index[k]; !ok {
+				index[k] = len(t.header)
+				t.header = append(t.header, k)
+			}
+		}
+
+		row := make([]string, len(t.header))
+		for k, v := range flat {
+			row[index[k]] = v
+		}
+		t.rows[i] = row
+	}
+
+	return t
+}
+
+func flatten(prefix string, obj map[string]interface{}, out map[string]string) {
+	for k, v := range obj {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+
+		if nested, ok := v.(map[string]interface{}); ok {
+			flatten(k, nested, out)
+			continue
+		}
+		out[k] = cellText(v)
+	}
+}
+
+// cellText renders a JSON value as the text of a table cell.
+// Arrays are kept as JSON, and null reads as empty
+func cellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// setPoints writes each row's point geometry into the columns mapped
+// to latitude and longitude, adding the columns if they are missing.
+// The geometry takes precedence over any coordinates in the properties
+func (t *table) setPoints(m *Mapping) {
+	lat, latOK := t.column(m, schema.PropLocationLatitude)
+	lng, lngOK := t.column(m, schema.PropLocationLongitude)
+
+	for i, p := range t.points {
+		if p == nil {
+			continue
+		}
+
+		if latOK {
+			t.set(i, lat, strconv.FormatFloat(p.lat, 'f', -1, 64))
+		}
+		if lngOK {
+			t.set(i, lng, strconv.FormatFloat(p.lng, 'f', -1, 64))
+		}
+	}
+}
+
+// column returns the index of the column mapped to target, adding
+// it to the header if it is missing. It reports false if the
+// target is not mapped
+func (t *table) column(m *Mapping, target string) (int, bool) {
+	col, ok := m.Fields[target]
+	if !ok {
+		return 0, false
+	}
+
+	if col.Index != nil {
+		return *col.Index, true
+	}
+
+	if i := headerIndex(t.header, col.Header); i >= 0 {
+		return i, true
+	}
+
+	t.header = append(t.header, col.Header)
+	return len(t.header) - 1, true
+}
+
+func (t *table) set(row, col int, v string) {
+	for len(t.rows[row]) <= col {
+		t.rows[row] = append(t.rows[row], "")
+	}
+	t.rows[row][col] = v
+}
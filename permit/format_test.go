@@ -0,0 +1,392 @@
+package permit
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		data string
+		want Format
+	}{
+		{name: "csv extension", path: "permits.csv", data: `{"type": "FeatureCollection"}`, want: FormatCSV},
+		{name: "geojson extension", path: "permits.geojson", data: `[]`, want: FormatGeoJSON},
+		{name: "csv content", path: "permits.txt", data: "locationid,Applicant\n1,Tacos\n", want: FormatCSV},
+		{name: "empty", path: "permits", data: "", want: FormatCSV},
+		{name: "json rows", path: "rows.json", data: ` [{"locationid": "1"}]`, want: FormatJSON},
+		{name: "json rows with bom", path: "rows.json", data: "\xEF\xBB\xBF[{}]", want: FormatJSON},
+		{name: "soda export", path: "rows.json", data: `{"meta": {"view": {}}, "data": []}`, want: FormatSODA},
+		{name: "feature collection", path: "permits.json", data: "\n{\"type\": \"FeatureCollection\", \"features\": []}", want: FormatGeoJSON},
+		{name: "other object", path: "permits.json", data: `{"rows": []}`, want: FormatJSON},
+		{name: "malformed object", path: "permits.json", data: `{"type": `, want: FormatJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.path, []byte(tt.data)); got != tt.want {
+				t.Errorf("DetectFormat(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// wantRecord holds the fields of a record checked by the read tests
+type wantRecord struct {
+	LocationID     string
+	Applicant      string
+	FacilityType   string
+	FoodItems      string
+	Address        string
+	Latitude       float32
+	Longitude      float32
+	LocationSource string
+	Status         string
+	ExpirationDate time.Time
+	Schedule       string
+	DaysHours      string
+	OpenHours      int
+}
+
+// tacos is the fully populated first row of every fixture
+var tacos = wantRecord{
+	LocationID:     "101",
+	Applicant:      "Tacos El Gordo",
+	FacilityType:   "Truck",
+	FoodItems:      "Tacos: Burritos",
+	Address:        "1 MARKET ST",
+	Latitude:       37.7941,
+	Longitude:      -122.3951,
+	LocationSource: LocationSourceDataset,
+	Status:         StatusApproved,
+	ExpirationDate: time.Date(2026, 11, 15, 0, 0, 0, 0, time.UTC),
+	Schedule:       "http://example.com/101.pdf",
+	DaysHours:      "Mo-Fr:11AM-2PM",
+	OpenHours:      15,
+}
+
+// curry is the second row of every fixture, which has no location
+var curry = wantRecord{
+	LocationID:   "102",
+	Applicant:    "Curry Up Now",
+	FacilityType: "Truck",
+	FoodItems:    "Curry: Samosas",
+	Status:       StatusRequested,
+}
+
+func TestRead(t *testing.T) {
+	// the CSV's second row is located from its State Plane x and y
+	curryCSV := curry
+	curryCSV.Address = "2 MISSION ST"
+	curryCSV.Latitude = 37.7901
+	curryCSV.Longitude = -122.3987
+	curryCSV.LocationSource = LocationSourceStatePlane
+
+	tests := []struct {
+		fixture string
+		format  Format
+		records []wantRecord
+		rejects map[int]string
+	}{
+		{
+			fixture: "permits.csv",
+			format:  FormatCSV,
+			records: []wantRecord{tacos, curryCSV},
+			rejects: map[int]string{3: `invalid latitude "95"`},
+		},
+		{
+			fixture: "permits.json",
+			format:  FormatJSON,
+			records: []wantRecord{tacos, curry},
+		},
+		{
+			fixture: "permits_soda.json",
+			format:  FormatSODA,
+			records: []wantRecord{tacos, curry},
+		},
+		{
+			// the point geometry takes precedence over the properties'
+			// coordinates, and other geometries are ignored
+			fixture: "permits.geojson",
+			format:  FormatGeoJSON,
+			records: []wantRecord{tacos, curry, {
+				LocationID:     "103",
+				Applicant:      "Polygon Pies",
+				Latitude:       37.75,
+				Longitude:      -122.41,
+				LocationSource: LocationSourceDataset,
+				Status:         StatusApproved,
+			}},
+			rejects: map[int]string{4: `invalid latitude "95"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			ds, err := Read(filepath.Join("testdata", tt.fixture), FormatAuto, nil)
+			if err != nil {
+				t.Fatalf("Read: %s", err)
+			}
+
+			if ds.Format != tt.format {
+				t.Errorf("format = %q, want %q", ds.Format, tt.format)
+			}
+
+			if ds.Len() != len(tt.records)+len(tt.rejects) {
+				t.Errorf("read %d rows, want %d", ds.Len(), len(tt.records)+len(tt.rejects))
+			}
+
+			if len(ds.Records) != len(tt.records) {
+				t.Fatalf("read %d records, want %d", len(ds.Records), len(tt.records))
+			}
+			for i, want := range tt.records {
+				checkRecord(t, ds.Records[i], want)
+			}
+
+			if len(ds.Rejects) != len(tt.rejects) {
+				t.Fatalf("rejected %d rows, want %d: %+v", len(ds.Rejects), len(tt.rejects), ds.Rejects)
+			}
+			for _, rej := range ds.Rejects {
+				if want, ok := tt.rejects[rej.Row]; !ok || rej.Reason != want {
+					t.Errorf("row %d rejected with %q, want %q", rej.Row, rej.Reason, want)
+				}
+			}
+		})
+	}
+}
+
+func checkRecord(t *testing.T, got Record, want wantRecord) {
+	t.Helper()
+
+	if got.City != DefaultCity {
+		t.Errorf("record %s: city = %q, want %q", want.LocationID, got.City, DefaultCity)
+	}
+
+	strs := []struct {
+		field     string
+		got, want string
+	}{
+		{"location id", got.LocationID, want.LocationID},
+		{"applicant", got.Applicant, want.Applicant},
+		{"facility type", got.FacilityType, want.FacilityType},
+		{"food items", got.FoodItems, want.FoodItems},
+		{"address", got.Address, want.Address},
+		{"location source", got.LocationSource, want.LocationSource},
+		{"status", got.Status, want.Status},
+		{"schedule", got.Schedule, want.Schedule},
+		{"days/hours", got.DaysHours, want.DaysHours},
+	}
+	for _, s := range strs {
+		if s.got != s.want {
+			t.Errorf("record %s: %s = %q, want %q", want.LocationID, s.field, s.got, s.want)
+		}
+	}
+
+	if !near(got.Latitude, want.Latitude) || !near(got.Longitude, want.Longitude) {
+		t.Errorf("record %s: location = %v,%v, want %v,%v",
+			want.LocationID, got.Latitude, got.Longitude, want.Latitude, want.Longitude)
+	}
+
+	if !got.ExpirationDate.Equal(want.ExpirationDate) {
+		t.Errorf("record %s: expiration date = %s, want %s",
+			want.LocationID, got.ExpirationDate, want.ExpirationDate)
+	}
+
+	if len(got.OpenHours) != want.OpenHours {
+		t.Errorf("record %s: open for %d hours a week, want %d",
+			want.LocationID, len(got.OpenHours), want.OpenHours)
+	}
+}
+
+// near reports whether two coordinates are within about 10 meters
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		format  Format
+		want    string
+	}{
+		{
+			name:    "missing required column",
+			fixture: "missing_applicant.csv",
+			want:    `column "Applicant" for target "name" not found in header`,
+		},
+		{
+			name:    "not a feature collection",
+			fixture: "permits.json",
+			format:  FormatGeoJSON,
+			want:    "failed to read geojson",
+		},
+		{
+			name:    "unknown format",
+			fixture: "permits.csv",
+			format:  "xml",
+			want:    `unknown dataset format "xml"`,
+		},
+		{
+			name:    "missing file",
+			fixture: "missing.csv",
+			want:    "failed to open dataset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(filepath.Join("testdata", tt.fixture), tt.format, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Read error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBadGeometry(t *testing.T) {
+	ds, err := Read(filepath.Join("testdata", "bad_coordinates.geojson"), FormatAuto, nil)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+
+	if len(ds.Records) != 1 || len(ds.Rejects) != 1 {
+		t.Fatalf("read %d records and %d rejects, want 1 and 1", len(ds.Records), len(ds.Rejects))
+	}
+
+	rej := ds.Rejects[0]
+	if rej.Row != 2 || rej.LocationID != "102" || !strings.Contains(rej.Reason, "invalid point coordinates") {
+		t.Errorf("reject = %+v, want row 2 rejected for its coordinates", rej)
+	}
+}
+
+func TestReadMissingOptionalColumn(t *testing.T) {
+	ds, err := Read(filepath.Join("testdata", "missing_optional.csv"), FormatAuto, nil)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+
+	if len(ds.Records) != 1 || len(ds.Rejects) != 0 {
+		t.Fatalf("read %d records and %d rejects, want 1 and 0", len(ds.Records), len(ds.Rejects))
+	}
+	if ds.Records[0].Address != "" {
+		t.Errorf("address = %q, want it empty", ds.Records[0].Address)
+	}
+}
+
+func TestReadDuplicateLocationID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "duplicates.csv")
+	csv := "locationid,Applicant,FacilityType,FoodItems,Latitude,Longitude,Status,ExpirationDate,Schedule,dayshours\n" +
+		"101,Tacos El Gordo,Truck,Tacos,37.7941,-122.3951,APPROVED,,,\n" +
+		"101,Tacos El Flaco,Truck,Tacos,37.7941,-122.3951,APPROVED,,,\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := Read(path, FormatAuto, nil)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+
+	if len(ds.Records) != 1 || ds.Records[0].Applicant != "Tacos El Gordo" {
+		t.Errorf("records = %+v, want only the first row", ds.Records)
+	}
+	if len(ds.Rejects) != 1 || ds.Rejects[0].Reason != "duplicate location id, first seen on row 1" {
+		t.Errorf("rejects = %+v, want the second row", ds.Rejects)
+	}
+}
+
+func TestReadUnparseableHours(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hours.csv")
+	csv := "locationid,Applicant,FacilityType,FoodItems,Latitude,Longitude,Status,ExpirationDate,Schedule,dayshours\n" +
+		"101,Tacos El Gordo,Truck,Tacos,37.7941,-122.3951,APPROVED,,,Mo-Fr:11AM-2PM\n" +
+		"102,Curry Up Now,Truck,Curry,37.7941,-122.3951,APPROVED,,,lunch on weekdays\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := Read(path, FormatAuto, nil)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+
+	// the row is kept, with its open hours unknown
+	if len(ds.Records) != 2 || len(ds.Rejects) != 0 {
+		t.Fatalf("read %d records and %d rejects, want 2 and 0", len(ds.Records), len(ds.Rejects))
+	}
+
+	rec := ds.Records[1]
+	if rec.DaysHours != "lunch on weekdays" || rec.OpenHours != nil {
+		t.Errorf("days/hours = %q with %d open hours, want it kept with none", rec.DaysHours, len(rec.OpenHours))
+	}
+
+	if len(ds.Warnings) != 1 || ds.Warnings[0].Row != 2 || ds.Warnings[0].LocationID != "102" {
+		t.Errorf("warnings = %+v, want one for row 2", ds.Warnings)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rec      Record
+		err      bool
+		warnings int
+		source   string
+		open     int
+	}{
+		{
+			name:   "located",
+			rec:    Record{Latitude: 37.7941, Longitude: -122.3951, DaysHours: "Mo-Fr:11AM-2PM"},
+			source: LocationSourceDataset,
+			open:   15,
+		},
+		{
+			name: "unlocated",
+			rec:  Record{LocationSource: LocationSourceStatePlane},
+		},
+		{
+			name:     "free text hours",
+			rec:      Record{DaysHours: "lunch on weekdays", OpenHours: []int{1, 2}},
+			warnings: 1,
+		},
+		{
+			name: "latitude out of range",
+			rec:  Record{Latitude: 91},
+			err:  true,
+		},
+		{
+			name: "longitude out of range",
+			rec:  Record{Longitude: -181},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tt.rec
+			warnings, err := rec.Validate()
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(warnings) != tt.warnings {
+				t.Errorf("warnings = %q, want %d", warnings, tt.warnings)
+			}
+			if rec.LocationSource != tt.source {
+				t.Errorf("location source = %q, want %q", rec.LocationSource, tt.source)
+			}
+			if len(rec.OpenHours) != tt.open {
+				t.Errorf("open for %d hours a week, want %d", len(rec.OpenHours), tt.open)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package permit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/parkerduckworth/lonchera/recommender/geo"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/spf13/viper"
+)
+
+// Mapping targets which are not FoodTruck schema properties. Every other
+// target is named after the FoodTruck schema property it populates
+const (
+	// TargetLocationID is the dataset's own row ID
+	TargetLocationID = "location_id"
+
+	// TargetX and TargetY are State Plane coordinates in US survey feet,
+	// used to locate rows which have no latitude and longitude
+	TargetX = "x"
+	TargetY = "y"
+)
+
+// sfStatePlane is the EPSG code of the State Plane zone used
+// by the San Francisco dataset, California zone 3 (ftUS)
+const sfStatePlane = 2227
+
+// targets lists every field a Mapping may populate
+var targets = map[string]bool{
+	TargetLocationID:             true,
+	TargetX:                      true,
+	TargetY:                      true,
+	schema.PropAddress:           true,
+	schema.PropName:              true,
+	schema.PropFacilityType:      true,
+	schema.PropFoodItems:         true,
+	schema.PropLocationLatitude:  true,
+	schema.PropLocationLongitude: true,
+	schema.PropStatus:            true,
+	schema.PropExpirationDate:    true,
+	schema.PropSchedule:          true,
+	schema.PropDaysHours:         true,
+}
+
+// Value transforms which may be applied to a column
+const (
+	TransformTrim  = "trim"
+	TransformUpper = "upper"
+	TransformLower = "lower"
+	TransformTitle = "title"
+)
+
+// Mapping describes how the columns of a permit file map onto the
+// fields of a Record, allowing datasets from other cities to be read
+type Mapping struct {
+	// City is the key of the city the dataset belongs to,
+	// e.g. san-francisco, which is stored on every record
+	City string
+
+	// DateLayout is the Go time layout of date columns,
+	// unless overridden by a column's own layout
+	DateLayout string
+
+	// StatePlane is the EPSG code of the State Plane zone of the
+	// x and y targets, e.g. 2227. If zero, rows without a latitude
+	// and longitude are left unlocated
+	StatePlane int
+
+	// Fields maps each target to the column it is read from
+	Fields map[string]Column
+}
+
+// Column locates a single column in a permit file, by
+// header name or by zero-based index, and describes the
+// transforms applied to its values. An optional column
+// whose header is missing reads as empty
+type Column struct {
+	Header     string
+	Index      *int
+	Transforms []string
+	Layout     string
+	Optional   bool
+}
+
+// DefaultMapping returns the mapping for the San Francisco dataset
+func DefaultMapping() *Mapping {
+	return &Mapping{
+		City:       DefaultCity,
+		DateLayout: dateLayout,
+		StatePlane: sfStatePlane,
+		Fields: map[string]Column{
+			TargetLocationID:             {Header: "locationid"},
+			schema.PropName:              {Header: "Applicant"},
+			schema.PropFacilityType:      {Header: "FacilityType"},
+			schema.PropFoodItems:         {Header: "FoodItems"},
+			schema.PropLocationLatitude:  {Header: "Latitude"},
+			schema.PropLocationLongitude: {Header: "Longitude"},
+			schema.PropStatus:            {Header: "Status"},
+			schema.PropExpirationDate:    {Header: "ExpirationDate"},
+			schema.PropSchedule:          {Header: "Schedule"},
+			schema.PropDaysHours:         {Header: "dayshours"},
+			schema.PropAddress:           {Header: "Address", Optional: true},
+			TargetX:                      {Header: "X", Optional: true},
+			TargetY:                      {Header: "Y", Optional: true},
+		},
+	}
+}
+
+// LoadMapping reads a mapping from the YAML file at path
+func LoadMapping(path string) (*Mapping, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read mapping: %s", err)
+	}
+
+	var m Mapping
+	if err := v.Unmarshal(&m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mapping: %s", err)
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid mapping %s: %s", path, err)
+	}
+
+	return &m, nil
+}
+
+func (m *Mapping) validate() error {
+	if m.City == "" {
+		return fmt.Errorf("city must be set")
+	}
+	if !ValidCity(m.City) {
+		return fmt.Errorf("city %q must be lower case letters, digits and dashes", m.City)
+	}
+
+	if _, ok := geo.StatePlaneZone(m.StatePlane); m.StatePlane != 0 && !ok {
+		return fmt.Errorf("unsupported state plane zone EPSG:%d", m.StatePlane)
+	}
+
+	for target, col := range m.Fields {
+		if !targets[target] {
+			return fmt.Errorf("unknown target %q", target)
+		}
+
+		if col.Header == "" && col.Index == nil {
+			return fmt.Errorf("target %q needs a header or an index", target)
+		}
+		if col.Index != nil && *col.Index < 0 {
+			return fmt.Errorf("target %q has negative index %d", target, *col.Index)
+		}
+
+		for _, t := range col.Transforms {
+			switch t {
+			case TransformTrim, TransformUpper, TransformLower, TransformTitle:
+			default:
+				return fmt.Errorf("target %q has unknown transform %q", target, t)
+			}
+		}
+	}
+
+	for _, required := range []string{TargetLocationID, schema.PropName} {
+		if _, ok := m.Fields[required]; !ok {
+			return fmt.Errorf("target %q must be mapped", required)
+		}
+	}
+
+	return nil
+}
+
+// resolve finds the index of each mapped column in the header row.
+// Headers are matched exactly, or failing that, ignoring case, so
+// that the lower case field names of Socrata's JSON APIs match too
+func (m *Mapping) resolve(header []string) (map[string]int, error) {
+	cols := make(map[string]int, len(m.Fields))
+	for target, col := range m.Fields {
+		if col.Index != nil {
+			cols[target] = *col.Index
+			continue
+		}
+
+		i := headerIndex(header, col.Header)
+		if i < 0 && col.Optional {
+			continue
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("column %q for target %q not found in header", col.Header, target)
+		}
+		cols[target] = i
+	}
+
+	return cols, nil
+}
+
+// headerIndex returns the index of name in header, matched
+// exactly or ignoring case, or -1 if it is missing
+func headerIndex(header []string, name string) int {
+	folded := -1
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if h == name {
+			return i
+		}
+		if folded < 0 && strings.EqualFold(h, name) {
+			folded = i
+		}
+	}
+	return folded
+}
+
+// value reads and transforms the target's value from row.
+// Unmapped targets and missing columns read as empty
+func (m *Mapping) value(row []string, cols map[string]int, target string) string {
+	i, ok := cols[target]
+	if !ok || i >= len(row) {
+		return ""
+	}
+
+	v := row[i]
+	for _, t := range m.Fields[target].Transforms {
+		switch t {
+		case TransformTrim:
+			v = strings.TrimSpace(v)
+		case TransformUpper:
+			v = strings.ToUpper(v)
+		case TransformLower:
+			v = strings.ToLower(v)
+		case TransformTitle:
+			v = strings.Title(strings.ToLower(v))
+		}
+	}
+
+	return v
+}
+
+// layout returns the date layout of the target's column
+func (m *Mapping) layout(target string) string {
+	if l := m.Fields[target].Layout; l != "" {
+		return l
+	}
+	if m.DateLayout != "" {
+		return m.DateLayout
+	}
+	return dateLayout
+}
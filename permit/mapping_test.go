@@ -0,0 +1,93 @@
+package permit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeMapping writes a mapping of the first four columns by index,
+// but for the name column, described by nameColumn, to a file in dir
+func writeMapping(t *testing.T, dir, city, nameColumn string) string {
+	t.Helper()
+
+	yml := fmt.Sprintf(`city: %s
+fields:
+  location_id:
+    index: 0
+  name:
+    %s
+  latitude:
+    index: 2
+  longitude:
+    index: 3
+`, city, nameColumn)
+
+	path := filepath.Join(dir, "mapping.yml")
+	if err := os.WriteFile(path, []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMapping(t *testing.T) {
+	tests := []struct {
+		name, city, nameColumn string
+		err                    string
+	}{
+		{"by index", "portland", "index: 1", ""},
+		{"by header", "portland", `header: "Applicant"`, ""},
+		{"negative index", "portland", "index: -1", `target "name" has negative index -1`},
+		{"no column", "portland", "transforms: [trim]", `target "name" needs a header or an index`},
+		{"malformed city", "Portland", "index: 1", `city "Portland" must be lower case letters, digits and dashes`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := LoadMapping(writeMapping(t, t.TempDir(), tt.city, tt.nameColumn))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("LoadMapping: %s", err)
+				}
+				if m.City != tt.city {
+					t.Errorf("city = %q, want %q", m.City, tt.city)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReadMappedByIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := LoadMapping(writeMapping(t, dir, "portland", "index: 1"))
+	if err != nil {
+		t.Fatalf("LoadMapping: %s", err)
+	}
+
+	path := filepath.Join(dir, "permits.csv")
+	if err := os.WriteFile(path, []byte("id,vendor,lat,lng\n7,Cart Seven,45.52,-122.68\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := Read(path, FormatCSV, m)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+
+	if len(ds.Records) != 1 || len(ds.Rejects) != 0 {
+		t.Fatalf("read %d records and %d rejects, want 1 and 0", len(ds.Records), len(ds.Rejects))
+	}
+
+	rec := ds.Records[0]
+	if rec.City != "portland" || rec.LocationID != "7" || rec.Applicant != "Cart Seven" || rec.Latitude != 45.52 {
+		t.Errorf("record = %+v, want Cart Seven in portland", rec)
+	}
+}
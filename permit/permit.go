@@ -0,0 +1,292 @@
+// Package permit reads the city's Mobile Food Facility Permit dataset into
+// typed records. It is shared by the importer, which loads the records into
+// Weaviate, and by the in-memory recommender store, which serves them directly.
+// The columns of the dataset are located through a Mapping, and the dataset
+// may be published as CSV, JSON, a Socrata export or GeoJSON, so that datasets
+// published by other cities can be read as well.
+package permit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/recommender/geo"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+)
+
+// Permit statuses used by the dataset
+const (
+	StatusApproved  = "APPROVED"
+	StatusIssued    = "ISSUED"
+	StatusRequested = "REQUESTED"
+	StatusExpired   = "EXPIRED"
+	StatusSuspended = "SUSPEND"
+
+	// StatusRemoved is not used by the dataset. It marks objects whose
+	// permit vanished from the dataset, when imported with tombstones
+	StatusRemoved = "REMOVED"
+)
+
+// Sources of a record's location
+const (
+	// LocationSourceDataset is a location read from
+	// the dataset's latitude and longitude
+	LocationSourceDataset = "dataset"
+
+	// LocationSourceStatePlane is a location converted from the
+	// dataset's State Plane x and y, when it has no latitude and
+	// longitude
+	LocationSourceStatePlane = "state_plane"
+)
+
+// dateLayout is the format of the San Francisco dataset's date columns
+const dateLayout = "01/02/2006 03:04:05 PM"
+
+// socrataDateLayouts are the formats of dates in Socrata's JSON exports
+var socrataDateLayouts = []string{
+	"2006-01-02T15:04:05.000",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+// DefaultCity is the city of the bundled San Francisco dataset
+const DefaultCity = "san-francisco"
+
+// cityKey is the form of a city key: lower case words joined by dashes
+var cityKey = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// ValidCity reports whether key is a well-formed city key,
+// made of lower case letters, digits and dashes
+func ValidCity(key string) bool {
+	return cityKey.MatchString(key)
+}
+
+// DefaultCSVPath is the location of the San Francisco dataset,
+// relative to the repository root
+const DefaultCSVPath = "cmd/import/Mobile_Food_Facility_Permit.csv"
+
+// Record is a single row of the permit dataset
+type Record struct {
+	// Row is the 1-based position of the record in its dataset
+	Row int
+
+	// City is the key of the city whose dataset the record belongs to
+	City string
+
+	LocationID   string
+	Applicant    string
+	FacilityType string
+	FoodItems    string
+	Address      string
+
+	// Latitude and Longitude are zero when the record could not be
+	// located, in which case LocationSource is empty. Otherwise it
+	// is one of the LocationSource constants
+	Latitude       float32
+	Longitude      float32
+	LocationSource string
+
+	// Status is the permit status, e.g. APPROVED or EXPIRED, and
+	// ExpirationDate is zero when the permit has no expiration date
+	Status         string
+	ExpirationDate time.Time
+
+	// Schedule is a link to the permit's schedule document
+	Schedule string
+
+	// DaysHours is the raw operating days/hours, and OpenHours the
+	// hour-of-week slots parsed from it. Both are empty when the
+	// dataset does not list the truck's hours
+	DaysHours string
+	OpenHours []int
+}
+
+// ReadCSV reads every row of the permit CSV found at path, locating
+// columns through the mapping. If m is nil, DefaultMapping is used.
+// Unlike Read, it fails on the first invalid row
+func ReadCSV(path string, m *Mapping) ([]Record, error) {
+	ds, err := Read(path, FormatCSV, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ds.Rejects) > 0 {
+		r := ds.Rejects[0]
+		return nil, fmt.Errorf("invalid row %d: %s", r.Row, r.Reason)
+	}
+
+	return ds.Records, nil
+}
+
+// parseRow reads a single row into a record. On error, the record
+// holds whichever identifying fields could be read. Values which are
+// ignored rather than rejecting the row are described by warnings
+func (m *Mapping) parseRow(row []string, cols map[string]int) (rec Record, warnings []string, err error) {
+	rec = Record{
+		City:         m.City,
+		LocationID:   m.value(row, cols, TargetLocationID),
+		Applicant:    m.value(row, cols, schema.PropName),
+		FacilityType: m.value(row, cols, schema.PropFacilityType),
+		FoodItems:    m.value(row, cols, schema.PropFoodItems),
+		Address:      m.value(row, cols, schema.PropAddress),
+		Status:       m.value(row, cols, schema.PropStatus),
+		Schedule:     m.value(row, cols, schema.PropSchedule),
+		DaysHours:    m.value(row, cols, schema.PropDaysHours),
+	}
+
+	if rec.LocationID == "" {
+		err = fmt.Errorf("missing location id")
+		return
+	}
+
+	lat := m.value(row, cols, schema.PropLocationLatitude)
+	if rec.Latitude, err = parseFloat32(lat); err != nil || !validLatitude(rec.Latitude) {
+		err = fmt.Errorf("invalid latitude %q", lat)
+		return
+	}
+
+	lng := m.value(row, cols, schema.PropLocationLongitude)
+	if rec.Longitude, err = parseFloat32(lng); err != nil || !validLongitude(rec.Longitude) {
+		err = fmt.Errorf("invalid longitude %q", lng)
+		return
+	}
+
+	if rec.Latitude != 0 || rec.Longitude != 0 {
+		rec.LocationSource = LocationSourceDataset
+	} else if err = m.locateStatePlane(row, cols, &rec); err != nil {
+		return
+	}
+
+	if exp := m.value(row, cols, schema.PropExpirationDate); exp != "" {
+		rec.ExpirationDate, err = parseDate(m.layout(schema.PropExpirationDate), exp)
+		if err != nil {
+			err = fmt.Errorf("invalid expiration date %q", exp)
+			return
+		}
+	}
+
+	if w := rec.parseOpenHours(); w != "" {
+		warnings = append(warnings, w)
+	}
+
+	return
+}
+
+// parseOpenHours sets OpenHours from DaysHours. Hours are often free
+// text, which leaves OpenHours empty, so that whether the truck is open
+// is unknown, as for a truck without hours. It returns a warning then
+func (r *Record) parseOpenHours() string {
+	open, err := ParseDaysHours(r.DaysHours)
+	if err != nil {
+		r.OpenHours = nil
+		return fmt.Sprintf("invalid days/hours %q, open hours are unknown: %s", r.DaysHours, err)
+	}
+
+	r.OpenHours = open
+	return ""
+}
+
+// Validate checks a record built outside of a dataset, such as by the
+// admin API, with the rules rows are read with, and derives its
+// LocationSource and OpenHours as reading a row would, returning the
+// same warnings. The location id is not checked, as it can't change
+// once the record is stored
+func (r *Record) Validate() (warnings []string, err error) {
+	if !validLatitude(r.Latitude) {
+		return nil, fmt.Errorf("invalid latitude %v", r.Latitude)
+	}
+	if !validLongitude(r.Longitude) {
+		return nil, fmt.Errorf("invalid longitude %v", r.Longitude)
+	}
+
+	r.LocationSource = ""
+	if r.Latitude != 0 || r.Longitude != 0 {
+		r.LocationSource = LocationSourceDataset
+	}
+
+	if w := r.parseOpenHours(); w != "" {
+		warnings = append(warnings, w)
+	}
+
+	return warnings, nil
+}
+
+func validLatitude(lat float32) bool {
+	return lat >= -90 && lat <= 90
+}
+
+func validLongitude(lng float32) bool {
+	return lng >= -180 && lng <= 180
+}
+
+// ParseDate parses a date as the San Francisco dataset writes them,
+// or as Socrata's JSON exports do, which includes RFC 3339
+func ParseDate(in string) (time.Time, error) {
+	return parseDate(dateLayout, in)
+}
+
+// locateStatePlane sets the record's location from its State Plane
+// x and y, if the mapping has a State Plane zone and both are set
+func (m *Mapping) locateStatePlane(row []string, cols map[string]int, rec *Record) error {
+	zone, ok := geo.StatePlaneZone(m.StatePlane)
+	if !ok {
+		return nil
+	}
+
+	xs, ys := m.value(row, cols, TargetX), m.value(row, cols, TargetY)
+	x, err := strconv.ParseFloat(xs, 64)
+	if xs == "" || (err == nil && x == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid x %q", xs)
+	}
+
+	y, err := strconv.ParseFloat(ys, 64)
+	if ys == "" || (err == nil && y == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid y %q", ys)
+	}
+
+	p := zone.ToPoint(x, y)
+	rec.Latitude, rec.Longitude = p.Lat, p.Lng
+	rec.LocationSource = LocationSourceStatePlane
+	return nil
+}
+
+// parseDate parses in with the given layout, falling back to the
+// floating timestamps used by Socrata's JSON exports
+func parseDate(layout, in string) (time.Time, error) {
+	t, err := time.Parse(layout, in)
+	if err == nil {
+		return t, nil
+	}
+
+	for _, l := range socrataDateLayouts {
+		if t, ferr := time.Parse(l, in); ferr == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
+
+// parseFloat32 parses in, reading blank values, which JSON
+// formats use for missing coordinates, as zero
+func parseFloat32(in string) (parsed float32, err error) {
+	if in == "" {
+		return
+	}
+
+	p, err := strconv.ParseFloat(in, 32)
+	if err != nil {
+		err = fmt.Errorf("failed to parse float32: %s", in)
+		return
+	}
+
+	parsed = float32(p)
+	return
+}
@@ -0,0 +1,159 @@
+package permit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HoursPerWeek is the number of hour-of-week slots. Slot 0
+// is Sunday from 12AM to 1AM, slot 167 is Saturday from 11PM
+const HoursPerWeek = 7 * 24
+
+var weekdays = map[string]int{
+	"Su": 0, "Mo": 1, "Tu": 2, "We": 3, "Th": 4, "Fr": 5, "Sa": 6,
+}
+
+// HourOfWeek returns the hour-of-week slot containing t,
+// in t's own time zone
+func HourOfWeek(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// ParseDaysHours parses the dayshours column of the permit dataset into
+// the sorted hour-of-week slots during which the truck is open. Values
+// look like "Mo-Fr:7AM-8AM/10AM-11AM;Sa/Su:12PM-4PM". Hours which end at
+// or before they start run past midnight into the following day
+func ParseDaysHours(in string) ([]int, error) {
+	in = strings.TrimSpace(in)
+	if in == "" {
+		return nil, nil
+	}
+
+	open := make(map[int]bool)
+	for _, segment := range strings.Split(in, ";") {
+		parts := strings.SplitN(segment, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid days/hours %q", segment)
+		}
+
+		days, err := parseDays(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, span := range strings.Split(parts[1], "/") {
+			start, end, err := parseHours(span)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, d := range days {
+				for h := start; h < end; h++ {
+					open[(d*24+h)%HoursPerWeek] = true
+				}
+			}
+		}
+	}
+
+	slots := make([]int, 0, len(open))
+	for s := range open {
+		slots = append(slots, s)
+	}
+	sort.Ints(slots)
+
+	return slots, nil
+}
+
+// parseDays parses "Mo", "Mo-Fr" and "Mo/We/Fr" style day lists
+func parseDays(in string) ([]int, error) {
+	var days []int
+	for _, part := range strings.Split(strings.TrimSpace(in), "/") {
+		bounds := strings.SplitN(part, "-", 2)
+
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q", bounds[0])
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return nil, fmt.Errorf("invalid day %q", bounds[1])
+			}
+		}
+
+		// ranges such as Sa-Su wrap around the end of the week
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// parseHours parses a "7AM-8AM" style span into the hours of the day it
+// covers, as [start, end). end may exceed 24 when the span runs overnight
+func parseHours(in string) (start, end int, err error) {
+	bounds := strings.SplitN(strings.TrimSpace(in), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid hours %q", in)
+	}
+
+	from, err := parseClock(bounds[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to, err := parseClock(bounds[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if to <= from {
+		to += 24
+	}
+
+	return int(math.Floor(from)), int(math.Ceil(to)), nil
+}
+
+// parseClock parses a "7AM" or "7:30PM" style time into hours since midnight
+func parseClock(in string) (float64, error) {
+	in = strings.ToUpper(strings.TrimSpace(in))
+
+	var pm bool
+	switch {
+	case strings.HasSuffix(in, "AM"):
+	case strings.HasSuffix(in, "PM"):
+		pm = true
+	default:
+		return 0, fmt.Errorf("invalid time %q", in)
+	}
+
+	clock := strings.SplitN(in[:len(in)-2], ":", 2)
+	hour, err := strconv.Atoi(clock[0])
+	if err != nil || hour < 1 || hour > 12 {
+		return 0, fmt.Errorf("invalid time %q", in)
+	}
+
+	var minute int
+	if len(clock) == 2 {
+		minute, err = strconv.Atoi(clock[1])
+		if err != nil || minute < 0 || minute > 59 {
+			return 0, fmt.Errorf("invalid time %q", in)
+		}
+	}
+
+	hour %= 12
+	if pm {
+		hour += 12
+	}
+
+	return float64(hour) + float64(minute)/60, nil
+}
@@ -0,0 +1,37 @@
+package permit
+
+import (
+	"crypto/sha1"
+	"fmt"
+)
+
+// idNamespace is the UUID namespace from which record IDs are derived
+var idNamespace = [16]byte{
+	0x6c, 0x6f, 0x6e, 0x63, 0x68, 0x65, 0x72, 0x61,
+	0x8d, 0x2e, 0x4b, 0x1c, 0x9a, 0x53, 0x0f, 0x71,
+}
+
+// ID returns a deterministic UUID for the record, derived from its city and
+// locationid, so that re-importing the same row updates the same object.
+// Trucks of the default city were imported before there were cities, and
+// keep the IDs derived from their locationid alone
+func (r Record) ID() string {
+	if r.City == DefaultCity {
+		return uuidV5(idNamespace, r.LocationID)
+	}
+	return uuidV5(idNamespace, r.City+"/"+r.LocationID)
+}
+
+// uuidV5 builds a name-based UUID, as described in RFC 4122 section 4.3
+func uuidV5(namespace [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+	u[6] = (u[6] & 0x0f) | 0x50 // version 5
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
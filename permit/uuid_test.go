@@ -0,0 +1,31 @@
+package permit
+
+import "testing"
+
+func TestRecordID(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  Record
+		want string
+	}{
+		{
+			// the ID the row was given before there were cities
+			name: "default city",
+			rec:  Record{City: DefaultCity, LocationID: "1571753"},
+			want: "a31312b4-f5ab-5d17-90bf-288c9383fcbd",
+		},
+		{
+			name: "other city",
+			rec:  Record{City: "portland", LocationID: "1571753"},
+			want: "75ddfc65-f8d3-5922-8b91-1c1a36826f4c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rec.ID(); got != tt.want {
+				t.Errorf("ID() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
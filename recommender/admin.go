@@ -0,0 +1,239 @@
+package recommender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+const (
+	ErrStoreReadOnly        = "the store is read-only"
+	ErrTruckExists          = "food truck already exists"
+	ErrFailedToCreateTruck  = "failed to create food truck"
+	ErrFailedToUpdateTruck  = "failed to update food truck"
+	ErrFailedToDeleteTruck  = "failed to delete food truck"
+	ErrMissingTruckIdentity = "must provide city and locationId"
+	ErrInvalidCity          = "city must be lower case letters, digits and dashes, e.g. san-francisco"
+	ErrDatasetChanged       = "the dataset was replaced during the write, retry it"
+	errInvalidTruckFormat   = "invalid food truck: %s"
+)
+
+// TruckPatch holds the properties of a truck to change. Nil fields are
+// left as they are. A truck's city and location id can't be changed,
+// as its ID is derived from them
+type TruckPatch struct {
+	Name         *string
+	FacilityType *string
+	Fare         *string
+	Address      *string
+
+	// Latitude and Longitude locate the truck, setting its location
+	// source to dataset. Setting both to zero unlocates it
+	Latitude  *float32
+	Longitude *float32
+
+	Status *string
+
+	// ExpirationDate is cleared when set to the zero time
+	ExpirationDate *time.Time
+
+	Schedule *string
+	Hours    *string
+}
+
+// apply overlays the patch onto the record
+func (p *TruckPatch) apply(rec *permit.Record) {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+
+	set(&rec.Applicant, p.Name)
+	set(&rec.FacilityType, p.FacilityType)
+	set(&rec.FoodItems, p.Fare)
+	set(&rec.Address, p.Address)
+	set(&rec.Status, p.Status)
+	set(&rec.Schedule, p.Schedule)
+	set(&rec.DaysHours, p.Hours)
+
+	if p.Latitude != nil {
+		rec.Latitude = *p.Latitude
+	}
+	if p.Longitude != nil {
+		rec.Longitude = *p.Longitude
+	}
+	if p.ExpirationDate != nil {
+		rec.ExpirationDate = *p.ExpirationDate
+	}
+}
+
+// adminMu serializes the admin API's reads and writes, so that an
+// update isn't lost to another made between reading and replacing
+// the truck. It only covers the writes made by this server
+var adminMu sync.Mutex
+
+// writer returns the store as a store.Writer, or
+// fails if the store can't be written to
+func writer() (store.Writer, *failure.Error) {
+	w, ok := backend.(store.Writer)
+	if !ok {
+		return nil, failure.NewError(http.StatusNotImplemented, ErrStoreReadOnly, nil)
+	}
+	return w, nil
+}
+
+// CreateTruck stores the record as a new truck, under the ID an
+// import of the same record would give it. The record is checked
+// and its open hours parsed as the importer would
+func CreateTruck(ctx context.Context, rec permit.Record) (*Detail, *failure.Error) {
+	w, ferr := writer()
+	if ferr != nil {
+		return nil, ferr
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	if rec.City == "" || rec.LocationID == "" {
+		return nil, failure.NewError(http.StatusBadRequest, ErrMissingTruckIdentity, nil)
+	}
+	if !permit.ValidCity(rec.City) {
+		return nil, failure.NewError(http.StatusBadRequest, ErrInvalidCity, nil)
+	}
+
+	warnings, err := rec.Validate()
+	if err != nil {
+		return nil, failure.NewError(http.StatusBadRequest, fmt.Sprintf(errInvalidTruckFormat, err), err)
+	}
+
+	t := store.FromRecord(rec)
+	warn(t.ID, warnings)
+
+	err = w.Create(ctx, t)
+	if errors.Is(err, store.ErrExists) {
+		return nil, failure.NewError(http.StatusConflict, ErrTruckExists, err)
+	}
+	if err != nil {
+		return nil, writeFailure(err, ErrFailedToCreateTruck)
+	}
+
+	return &buildDetails([]store.Truck{t})[0], nil
+}
+
+// UpdateTruck applies the patch to the truck with the given ID, checking
+// the result as the importer would. It returns the truck as it was
+// before the update, and as it is after
+func UpdateTruck(ctx context.Context, id string, patch TruckPatch) (before, after *Detail, ferr *failure.Error) {
+	w, ferr := writer()
+	if ferr != nil {
+		return nil, nil, ferr
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	t, ferr := getForWrite(ctx, id, ErrFailedToUpdateTruck)
+	if ferr != nil {
+		return nil, nil, ferr
+	}
+
+	rec := permit.Record{
+		City:           t.City,
+		Applicant:      t.Name,
+		FacilityType:   t.FacilityType,
+		FoodItems:      t.FoodItems,
+		Address:        t.Address,
+		Latitude:       t.Latitude,
+		Longitude:      t.Longitude,
+		Status:         t.Status,
+		ExpirationDate: t.ExpirationDate,
+		Schedule:       t.Schedule,
+		DaysHours:      t.DaysHours,
+	}
+	patch.apply(&rec)
+
+	warnings, err := rec.Validate()
+	if err != nil {
+		return nil, nil, failure.NewError(http.StatusBadRequest, fmt.Sprintf(errInvalidTruckFormat, err), err)
+	}
+	warn(t.ID, warnings)
+
+	// a location converted from State Plane stays
+	// as it is, unless the patch moves the truck
+	if patch.Latitude == nil && patch.Longitude == nil {
+		rec.LocationSource = t.LocationSource
+	}
+
+	updated := store.FromRecord(rec)
+	updated.ID = t.ID
+
+	if err := w.Replace(ctx, updated); err != nil {
+		return nil, nil, writeFailure(err, ErrFailedToUpdateTruck)
+	}
+
+	details := buildDetails([]store.Truck{*t, updated})
+	return &details[0], &details[1], nil
+}
+
+// DeleteTruck deletes the truck with the given ID,
+// returning the truck as it was
+func DeleteTruck(ctx context.Context, id string) (*Detail, *failure.Error) {
+	w, ferr := writer()
+	if ferr != nil {
+		return nil, ferr
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	t, ferr := getForWrite(ctx, id, ErrFailedToDeleteTruck)
+	if ferr != nil {
+		return nil, ferr
+	}
+
+	if err := w.Delete(ctx, id); err != nil {
+		return nil, writeFailure(err, ErrFailedToDeleteTruck)
+	}
+
+	return &buildDetails([]store.Truck{*t})[0], nil
+}
+
+// writeFailure describes the error returned by a store write
+func writeFailure(err error, failed string) *failure.Error {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return failure.NewError(http.StatusNotFound, ErrTruckNotFound, err)
+	case errors.Is(err, store.ErrDatasetChanged):
+		return failure.NewError(http.StatusConflict, ErrDatasetChanged, err)
+	default:
+		return failure.NewError(http.StatusInternalServerError, failed, err)
+	}
+}
+
+// warn logs the warnings raised validating the truck
+func warn(id string, warnings []string) {
+	for _, w := range warnings {
+		log.Warnf("food truck %s: %s", id, w)
+	}
+}
+
+// getForWrite returns the truck about to be written
+func getForWrite(ctx context.Context, id, failed string) (*store.Truck, *failure.Error) {
+	t, err := backend.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, failure.NewError(http.StatusNotFound, ErrTruckNotFound, err)
+	}
+	if err != nil {
+		return nil, failure.NewError(http.StatusInternalServerError, failed, err)
+	}
+	return t, nil
+}
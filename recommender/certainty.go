@@ -0,0 +1,50 @@
+package recommender
+
+import (
+	"context"
+
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+// Certainty configures the minimum certainty of answers to a question
+type Certainty struct {
+	// Min is the certainty threshold of the first query
+	Min float32
+
+	// Relax enables progressive relaxation: when fewer results than
+	// requested come back, the query is retried with lower thresholds,
+	// down to the configured floor
+	Relax bool
+}
+
+// DefaultCertainty returns the certainty threshold set in the application
+// configuration, with progressive relaxation disabled
+func DefaultCertainty() Certainty {
+	return Certainty{Min: config.Conf.Ask.MinCertainty}
+}
+
+// askWithRelaxation runs the ask query at the requested certainty, relaxing
+// the threshold if requested until want trucks are found. The query's limit
+// may fetch more candidates than are wanted, which don't hold relaxation
+// back. The threshold that was finally used is returned alongside the trucks
+func askWithRelaxation(ctx context.Context, q store.AskQuery, c Certainty, want int) ([]store.Truck, float32, error) {
+	floor, step := config.Conf.Ask.RelaxFloor, config.Conf.Ask.RelaxStep
+	q.Certainty = c.Min
+
+	for {
+		trucks, err := backend.Ask(ctx, q)
+		if err != nil {
+			return nil, q.Certainty, err
+		}
+
+		if !c.Relax || len(trucks) >= want || q.Certainty <= floor {
+			return trucks, q.Certainty, nil
+		}
+
+		q.Certainty -= step
+		if step <= 0 || q.Certainty < floor {
+			q.Certainty = floor
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package recommender
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender/geo"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+const (
+	ErrFailedToListCities = "failed to list cities"
+)
+
+// City summarises the trucks loaded for one city
+type City struct {
+	Name   string       `json:"name"`
+	Trucks int          `json:"trucks"`
+	Bounds *BoundingBox `json:"bounds,omitempty"`
+}
+
+// BoundingBox is the smallest box containing every located
+// truck in a city. It is nil when no truck has a location
+type BoundingBox struct {
+	MinLatitude  float32 `json:"minLatitude"`
+	MinLongitude float32 `json:"minLongitude"`
+	MaxLatitude  float32 `json:"maxLatitude"`
+	MaxLongitude float32 `json:"maxLongitude"`
+}
+
+func (b *BoundingBox) extend(p geo.Point) *BoundingBox {
+	if b == nil {
+		return &BoundingBox{
+			MinLatitude:  p.Lat,
+			MinLongitude: p.Lng,
+			MaxLatitude:  p.Lat,
+			MaxLongitude: p.Lng,
+		}
+	}
+
+	if p.Lat < b.MinLatitude {
+		b.MinLatitude = p.Lat
+	}
+	if p.Lng < b.MinLongitude {
+		b.MinLongitude = p.Lng
+	}
+	if p.Lat > b.MaxLatitude {
+		b.MaxLatitude = p.Lat
+	}
+	if p.Lng > b.MaxLongitude {
+		b.MaxLongitude = p.Lng
+	}
+	return b
+}
+
+// cityCache holds the cities last listed, and the dataset version
+// and revision they were listed from, as listing them reads every
+// truck. It is emptied when the store is replaced
+var cityCache struct {
+	sync.Mutex
+	cities   []City
+	version  string
+	revision int
+}
+
+// Cities lists every city with trucks loaded in the store, with
+// their truck count and bounds, ordered by name. Every truck is
+// counted, whatever the status of its permit. The list is only
+// read again once the dataset is changed, by an import or a write
+func Cities(ctx context.Context) ([]City, *failure.Error) {
+	version, revision := DatasetVersion(ctx), DatasetRevision(ctx)
+
+	cityCache.Lock()
+	defer cityCache.Unlock()
+
+	if cityCache.cities != nil && cityCache.version == version && cityCache.revision == revision {
+		return cityCache.cities, nil
+	}
+
+	trucks, err := backend.All(ctx)
+	if err != nil {
+		return nil, failure.NewError(
+			http.StatusInternalServerError, ErrFailedToListCities, err)
+	}
+
+	cities := listCities(trucks)
+	cityCache.cities = cities
+	cityCache.version = version
+	cityCache.revision = revision
+
+	return cities, nil
+}
+
+// listCities summarises the trucks by city, ordered by name
+func listCities(trucks []store.Truck) []City {
+	byName := make(map[string]*City)
+	for _, t := range trucks {
+		c, ok := byName[t.City]
+		if !ok {
+			c = &City{Name: t.City}
+			byName[t.City] = c
+		}
+
+		c.Trucks++
+		if p := (geo.Point{Lat: t.Latitude, Lng: t.Longitude}); !p.IsZero() {
+			c.Bounds = c.Bounds.extend(p)
+		}
+	}
+
+	cities := make([]City, 0, len(byName))
+	for _, c := range byName {
+		cities = append(cities, *c)
+	}
+	sort.Slice(cities, func(i, j int) bool {
+		return cities[i].Name < cities[j].Name
+	})
+
+	return cities
+}
@@ -0,0 +1,64 @@
+package recommender
+
+import (
+	"context"
+
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+// CountByFare sets meta.Total to the number of trucks whose fare
+// answers the question, at the certainty and with the filter that
+// ByFare found its results with
+func CountByFare(ctx context.Context, question string, meta *Meta) {
+	count(ctx, meta, func(c store.Counter) (int, error) {
+		return c.CountAsk(ctx, store.AskQuery{
+			Question:  question,
+			Certainty: meta.Certainty,
+			Filter:    meta.filter.toStore(),
+		})
+	})
+}
+
+// CountByLocation sets meta.Total to the number of trucks
+// ByLocation could have returned, were there no limit
+func CountByLocation(ctx context.Context, coord *GeoCoordinates, meta *Meta) {
+	count(ctx, meta, func(c store.Counter) (int, error) {
+		return c.CountGeo(ctx, store.GeoQuery{
+			GeoRange: coord.toGeoRange(),
+			Filter:   meta.filter.toStore(),
+		})
+	})
+}
+
+// CountRecommend sets meta.Total to the number of trucks within
+// range of coord whose fare answers the question, at the certainty
+// and with the filter that Recommend found its results with
+func CountRecommend(ctx context.Context, question string, coord *GeoCoordinates, meta *Meta) {
+	within := coord.toGeoRange()
+
+	count(ctx, meta, func(c store.Counter) (int, error) {
+		return c.CountAsk(ctx, store.AskQuery{
+			Question:  question,
+			Certainty: meta.Certainty,
+			Within:    &within,
+			Filter:    meta.filter.toStore(),
+		})
+	})
+}
+
+// count sets meta.Total with the store's Counter. The total is only
+// metadata, so if the store can't count, or fails to, it is left nil
+func count(ctx context.Context, meta *Meta, fn func(store.Counter) (int, error)) {
+	c, ok := backend.(store.Counter)
+	if !ok {
+		return
+	}
+
+	total, err := fn(c)
+	if err != nil {
+		log.Warnf("failed to count results: %s", err)
+		return
+	}
+	meta.Total = &total
+}
@@ -4,55 +4,57 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/parkerduckworth/lonchera/app/config"
 	"github.com/parkerduckworth/lonchera/failure"
-	"github.com/parkerduckworth/lonchera/recommender/schema"
-	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
-	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/parkerduckworth/lonchera/recommender/store"
 )
 
 const (
 	ErrFailedToRecommendByFare = "failed to recommend by fare"
 )
 
-func ByFare(ctx context.Context, question string, limit int) (*Response, *failure.Error) {
-	client := weaviate.New(config.Conf.Weaviate)
-
-	fields := []graphql.Field{
-		{Name: schema.PropName},
-		{Name: schema.PropFacilityType},
-		{Name: schema.PropFoodItems},
-		{Name: schema.PropLocation, Fields: []graphql.Field{
-			{Name: schema.PropLocationLatitude},
-			{Name: schema.PropLocationLongitude},
-		}},
-		{Name: schema.PropAdditional, Fields: []graphql.Field{
-			{Name: schema.PropAdditionalCertainty},
-		}},
+// ByFare recommends the trucks whose fare best answers the question.
+// The returned Meta reports the certainty threshold that was used,
+// and the dataset version
+func ByFare(ctx context.Context, question string, certainty Certainty, filter Filter, limit int) (*Response, *Meta, *failure.Error) {
+	trucks, used, err := askWithRelaxation(ctx, store.AskQuery{
+		Question: question,
+		Filter:   filter.toStore(),
+		Limit:    limit,
+	}, certainty, limit)
+
+	if err != nil {
+		return nil, nil, failure.NewError(
+			http.StatusInternalServerError, ErrFailedToRecommendByFare, err)
 	}
 
-	ask := client.GraphQL().AskArgBuilder().
-		WithQuestion(question).
-		WithCertainty(0.6)
+	return buildResponse(trucks, filter), newMeta(ctx, used, limit, filter), nil
+}
 
-	result, err := client.GraphQL().Get().
-		WithClassName(schema.ClassName).
-		WithFields(fields...).
-		WithAsk(ask).
-		WithLimit(limit).
-		Do(ctx)
+// ByFarePage is ByFare, a page at a time. The threshold used for the
+// first page, relaxed or not, is kept for every page after it
+func ByFarePage(ctx context.Context, question string, certainty Certainty, filter Filter, page Page) (*PagedResponse, *Meta, *failure.Error) {
+	cur, ferr := page.open(ctx, queryKey("fare", question, certainty, filter), &filter)
+	if ferr != nil {
+		return nil, nil, ferr
+	}
 
-	err = checkWeaviateResponse(result, err)
-	if err != nil {
-		return nil, failure.NewError(
-			http.StatusInternalServerError, ErrFailedToRecommendByFare, err)
+	if page.Cursor != "" {
+		certainty = Certainty{Min: cur.Certainty}
 	}
 
-	resp, err := buildResponse(result)
+	trucks, used, err := askWithRelaxation(ctx, store.AskQuery{
+		Question: question,
+		Filter:   filter.toStore(),
+		Limit:    cur.limit(),
+	}, certainty, cur.end())
+
 	if err != nil {
-		return nil, failure.NewError(
+		return nil, nil, failure.NewError(
 			http.StatusInternalServerError, ErrFailedToRecommendByFare, err)
 	}
 
-	return resp, nil
+	cur.Certainty = used
+	trucks, next := cur.cut(trucks)
+
+	return newPagedResponse(buildResponse(trucks, filter), next), newMeta(ctx, used, cur.Size, filter), nil
 }
@@ -0,0 +1,112 @@
+package recommender
+
+import (
+	"sync"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+// Filter narrows and annotates the trucks considered by a recommendation
+type Filter struct {
+	// City, if set, restricts the recommendation to one city's trucks
+	City string
+
+	// OpenAt is the time used to decide whether each truck is open.
+	// It defaults to now, and is read in the city's time zone
+	OpenAt time.Time
+
+	// OpenOnly excludes trucks which are not known to be open at OpenAt
+	OpenOnly bool
+
+	// IncludeInactive includes trucks whose permit is not active, or
+	// has expired. These are excluded by default, but may be wanted by
+	// admin tools
+	IncludeInactive bool
+}
+
+var (
+	// defaultZone is the configured time zone, and cityZones
+	// the time zones configured for cities of their own
+	defaultZone *time.Location
+	cityZones   map[string]*time.Location
+	zonesOnce   sync.Once
+)
+
+// loadZone loads the named time zone, falling
+// back to fallback if it cannot be loaded
+func loadZone(name string, fallback *time.Location) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Warnf("failed to load time zone %q, using %s: %s", name, fallback, err)
+		return fallback
+	}
+	return loc
+}
+
+// loadZones loads the configured time zones, once. The
+// default falls back to UTC if it cannot be loaded
+func loadZones() {
+	zonesOnce.Do(func() {
+		defaultZone = loadZone(config.Conf.TimeZone, time.UTC)
+
+		cityZones = make(map[string]*time.Location, len(config.Conf.Cities))
+		for key, c := range config.Conf.Cities {
+			if c.TimeZone != "" {
+				cityZones[key] = loadZone(c.TimeZone, defaultZone)
+			}
+		}
+	})
+}
+
+// cityZone returns the time zone configured for the city, which
+// is the default time zone unless the city has one of its own
+func cityZone(city string) *time.Location {
+	loadZones()
+
+	if loc, ok := cityZones[city]; ok {
+		return loc
+	}
+	return defaultZone
+}
+
+// cityTime returns t in the time zone configured for the city
+func cityTime(city string, t time.Time) time.Time {
+	return t.In(cityZone(city))
+}
+
+// openAt returns the time used to decide whether trucks are
+// open, in the time zone of the filter's city, if it has one
+func (f Filter) openAt() time.Time {
+	if f.OpenAt.IsZero() {
+		return cityTime(f.City, time.Now())
+	}
+	return cityTime(f.City, f.OpenAt)
+}
+
+func (f Filter) toStore() store.Filter {
+	sf := store.Filter{City: f.City}
+	if f.OpenOnly {
+		at := f.openAt()
+		sf.OpenAt = &at
+
+		// trucks of every city are considered, each open
+		// or not in the time zone of its own city
+		if f.City == "" {
+			loadZones()
+			sf.Zones = cityZones
+		}
+	}
+
+	if !f.IncludeInactive {
+		sf.Statuses = config.Conf.Permits.ActiveStatuses
+		if !config.Conf.Permits.IgnoreExpiration {
+			now := time.Now()
+			sf.ValidAt = &now
+		}
+	}
+
+	return sf
+}
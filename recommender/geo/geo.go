@@ -0,0 +1,51 @@
+// Package geo contains the geographic math shared by the recommender
+// and its storage backends
+package geo
+
+import "math"
+
+const (
+	earthRadiusMeters = 6371e3
+	milesPerMeter     = 0.00062137
+	metersPerMile     = 1609.344
+)
+
+// Point is a WGS84 latitude/longitude pair, in degrees
+type Point struct {
+	Lat float32
+	Lng float32
+}
+
+// IsZero reports whether the point is the (0, 0) placeholder
+// used by the permit dataset for trucks without a location
+func (p Point) IsZero() bool {
+	return p.Lat == 0 && p.Lng == 0
+}
+
+// Distance uses the haversine distance formula to calculate
+// the number of meters between src and dst
+func Distance(src, dst Point) float64 {
+	φ1 := float64(src.Lat * math.Pi / 180) // φ, λ in radians
+	φ2 := float64(dst.Lat * math.Pi / 180)
+
+	Δφ := float64((dst.Lat - src.Lat) * math.Pi / 180)
+	Δλ := float64((dst.Lng - src.Lng) * math.Pi / 180)
+
+	a := math.Sin(Δφ/2)*math.Sin(Δφ/2) +
+		math.Cos(φ1)*math.Cos(φ2)*
+			math.Sin(Δλ/2)*math.Sin(Δλ/2)
+
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusMeters * c
+}
+
+// MetersToMiles converts a distance in meters to miles
+func MetersToMiles(m float64) float64 {
+	return m * milesPerMeter
+}
+
+// MilesToMeters converts a distance in miles to meters
+func MilesToMeters(m float32) float32 {
+	return m * metersPerMile
+}
@@ -0,0 +1,186 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+const metersPerDegreeLat = 111320
+
+// Grid is a spatial index which buckets points into square cells of
+// a fixed number of degrees. Range queries only visit the cells which
+// overlap the bounding box of the search radius
+type Grid struct {
+	cellSize float64
+	cells    map[cell][]int
+	points   []Point
+	min, max cell
+}
+
+type cell struct {
+	row int
+	col int
+}
+
+// Hit is a point returned by a Grid query. Index is the position
+// of the point in the slice the Grid was built from
+type Hit struct {
+	Index  int
+	Meters float64
+}
+
+// NewGrid indexes points into cells of cellSize degrees. Zero
+// points are left out of the index, as they have no location
+func NewGrid(points []Point, cellSize float64) *Grid {
+	g := &Grid{
+		cellSize: cellSize,
+		cells:    make(map[cell][]int),
+		points:   points,
+	}
+
+	first := true
+	for i, p := range points {
+		if p.IsZero() {
+			continue
+		}
+
+		c := g.cellOf(float64(p.Lat), float64(p.Lng))
+		g.cells[c] = append(g.cells[c], i)
+
+		if first {
+			g.min, g.max = c, c
+			first = false
+			continue
+		}
+
+		g.min.row, g.max.row = minInt(g.min.row, c.row), maxInt(g.max.row, c.row)
+		g.min.col, g.max.col = minInt(g.min.col, c.col), maxInt(g.max.col, c.col)
+	}
+
+	return g
+}
+
+// Len returns the number of indexed points
+func (g *Grid) Len() int {
+	var n int
+	for _, idx := range g.cells {
+		n += len(idx)
+	}
+	return n
+}
+
+// Within returns every indexed point within meters of center,
+// nearest first. If keep is not nil, only the points for which
+// keep returns true are included
+func (g *Grid) Within(center Point, meters float64, keep func(index int) bool) []Hit {
+	if len(g.cells) == 0 {
+		return nil
+	}
+
+	lat, lng := float64(center.Lat), float64(center.Lng)
+	dLat := meters / metersPerDegreeLat
+	dLng := meters / (metersPerDegreeLat * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+
+	lo := g.cellOf(lat-dLat, lng-dLng)
+	hi := g.cellOf(lat+dLat, lng+dLng)
+
+	var hits []Hit
+	for row := maxInt(lo.row, g.min.row); row <= minInt(hi.row, g.max.row); row++ {
+		for col := maxInt(lo.col, g.min.col); col <= minInt(hi.col, g.max.col); col++ {
+			for _, i := range g.cells[cell{row, col}] {
+				if keep != nil && !keep(i) {
+					continue
+				}
+
+				d := Distance(center, g.points[i])
+				if d <= meters {
+					hits = append(hits, Hit{Index: i, Meters: d})
+				}
+			}
+		}
+	}
+
+	sortHits(hits)
+	return hits
+}
+
+// Nearest returns the k indexed points closest to center, nearest
+// first. If maxMeters is positive, points further away are ignored.
+// If keep is not nil, only the points for which keep returns true
+// are included
+func (g *Grid) Nearest(center Point, k int, maxMeters float64, keep func(index int) bool) []Hit {
+	if k < 1 || len(g.cells) == 0 {
+		return nil
+	}
+
+	limit := maxMeters
+	if limit <= 0 {
+		limit = math.Inf(1)
+	}
+
+	// grow the search radius one cell at a time until enough points are
+	// found, or until the radius covers every cell in the index
+	radius := g.cellSize * metersPerDegreeLat
+	for {
+		r := math.Min(radius, limit)
+		hits := g.Within(center, r, keep)
+		if len(hits) >= k {
+			return hits[:k]
+		}
+
+		if r == limit || g.covers(center, r) {
+			return hits
+		}
+
+		radius *= 2
+	}
+}
+
+// covers reports whether a circle of the given radius around
+// center contains every cell in the index
+func (g *Grid) covers(center Point, meters float64) bool {
+	south := float64(g.min.row) * g.cellSize
+	north := float64(g.max.row+1) * g.cellSize
+	west := float64(g.min.col) * g.cellSize
+	east := float64(g.max.col+1) * g.cellSize
+
+	for _, lat := range []float64{south, north} {
+		for _, lng := range []float64{west, east} {
+			if Distance(center, Point{Lat: float32(lat), Lng: float32(lng)}) > meters {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (g *Grid) cellOf(lat, lng float64) cell {
+	return cell{
+		row: int(math.Floor(lat / g.cellSize)),
+		col: int(math.Floor(lng / g.cellSize)),
+	}
+}
+
+func sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Meters == hits[j].Meters {
+			return hits[i].Index < hits[j].Index
+		}
+		return hits[i].Meters < hits[j].Meters
+	})
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+package geo
+
+import (
+	"math"
+)
+
+// usSurveyFoot is the length of a US survey foot in meters
+const usSurveyFoot = 1200.0 / 3937.0
+
+// GRS80 ellipsoid, used by NAD83. NAD83 and WGS84 differ by
+// around a meter, which is close enough to place a truck
+const (
+	grs80SemiMajorAxis = 6378137.0
+	grs80Flattening    = 1 / 298.257222101
+)
+
+// StatePlane is a NAD83 State Plane zone using the Lambert Conformal
+// Conic projection with two standard parallels, in US survey feet
+type StatePlane struct {
+	Name string
+
+	// standard parallels, latitude of origin and central
+	// meridian in degrees, and false easting/northing in feet
+	lat1, lat2, lat0, lon0 float64
+	falseEasting           float64
+	falseNorthing          float64
+}
+
+// statePlaneZones are the supported zones, by EPSG code
+var statePlaneZones = map[int]StatePlane{
+	2225: {"NAD83 / California zone 1 (ftUS)", dms(41, 40), dms(40, 0), dms(39, 20), -122, 6561666.667, 1640416.667},
+	2226: {"NAD83 / California zone 2 (ftUS)", dms(39, 50), dms(38, 20), dms(37, 40), -122, 6561666.667, 1640416.667},
+	2227: {"NAD83 / California zone 3 (ftUS)", dms(38, 26), dms(37, 4), dms(36, 30), -dms(120, 30), 6561666.667, 1640416.667},
+	2228: {"NAD83 / California zone 4 (ftUS)", dms(37, 15), dms(36, 0), dms(35, 20), -119, 6561666.667, 1640416.667},
+	2229: {"NAD83 / California zone 5 (ftUS)", dms(35, 28), dms(34, 2), dms(33, 30), -118, 6561666.667, 1640416.667},
+	2230: {"NAD83 / California zone 6 (ftUS)", dms(33, 53), dms(32, 47), dms(32, 10), -dms(116, 15), 6561666.667, 1640416.667},
+}
+
+// StatePlaneZone returns the State Plane zone with the given
+// EPSG code, e.g. 2227 for San Francisco's California zone 3
+func StatePlaneZone(epsg int) (StatePlane, bool) {
+	z, ok := statePlaneZones[epsg]
+	return z, ok
+}
+
+// ToPoint converts x (easting) and y (northing), in
+// US survey feet, to a latitude/longitude point
+func (z StatePlane) ToPoint(x, y float64) Point {
+	a := grs80SemiMajorAxis
+	e := math.Sqrt(2*grs80Flattening - grs80Flattening*grs80Flattening)
+
+	phi1, phi2 := radians(z.lat1), radians(z.lat2)
+	phi0, lambda0 := radians(z.lat0), radians(z.lon0)
+
+	m1, m2 := lccM(phi1, e), lccM(phi2, e)
+	t1, t2, t0 := lccT(phi1, e), lccT(phi2, e), lccT(phi0, e)
+
+	n := (math.Log(m1) - math.Log(m2)) / (math.Log(t1) - math.Log(t2))
+	f := m1 / (n * math.Pow(t1, n))
+	r0 := a * f * math.Pow(t0, n)
+
+	dx := (x - z.falseEasting) * usSurveyFoot
+	dy := r0 - (y-z.falseNorthing)*usSurveyFoot
+
+	r := math.Copysign(math.Hypot(dx, dy), n)
+	theta := math.Atan2(dx, dy)
+	t := math.Pow(r/(a*f), 1/n)
+
+	// latitude has no closed form, but converges in a few iterations
+	phi := math.Pi/2 - 2*math.Atan(t)
+	for i := 0; i < 10; i++ {
+		es := e * math.Sin(phi)
+		next := math.Pi/2 - 2*math.Atan(t*math.Pow((1-es)/(1+es), e/2))
+		if math.Abs(next-phi) < 1e-12 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+
+	lambda := theta/n + lambda0
+	return Point{Lat: float32(degrees(phi)), Lng: float32(degrees(lambda))}
+}
+
+func lccM(phi, e float64) float64 {
+	es := e * math.Sin(phi)
+	return math.Cos(phi) / math.Sqrt(1-es*es)
+}
+
+func lccT(phi, e float64) float64 {
+	es := e * math.Sin(phi)
+	return math.Tan(math.Pi/4-phi/2) / math.Pow((1-es)/(1+es), e/2)
+}
+
+// dms returns degrees and minutes as decimal degrees
+func dms(deg, min float64) float64 {
+	return deg + min/60
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
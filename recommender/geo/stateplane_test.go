@@ -0,0 +1,103 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// within is how close a converted point must be to its control point,
+// in meters. Points are float32, which alone accounts for up to half a meter
+const within = 1.0
+
+func TestStatePlaneToPoint(t *testing.T) {
+	tests := []struct {
+		name string
+		epsg int
+		x, y float64
+		want Point
+	}{
+		// X/Y and the published latitude/longitude of
+		// rows of San Francisco's permit dataset
+		{"3750 18TH ST", 2227, 6004575.869, 2105666.974, Point{37.76201920035647, -122.42730642251331}},
+		{"2535 TAYLOR ST", 2227, 6008186.35457, 2121568.81783, Point{37.805885350100986, -122.41594524663745}},
+		{"Assessors Block 7283/Lot004", 2227, 5985417.15, 2091453.145, Point{37.72188970870838, -122.4925212449949}},
+		{"290 TOWNSEND ST", 2227, 6014084.346, 2111203.786, Point{37.77775521656862, -122.394807823179}},
+		{"1234 GREAT HWY", 2227, 5980806.006, 2106745.676, Point{37.76360804110198, -122.50959579624613}},
+
+		// each zone's false easting and northing lie
+		// on its latitude of origin and central meridian
+		{"zone 1 origin", 2225, 6561666.667, 1640416.667, Point{39 + 20.0/60, -122}},
+		{"zone 3 origin", 2227, 6561666.667, 1640416.667, Point{36.5, -120.5}},
+		{"zone 5 origin", 2229, 6561666.667, 1640416.667, Point{33.5, -118}},
+		{"zone 6 origin", 2230, 6561666.667, 1640416.667, Point{32 + 10.0/60, -116.25}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z, ok := StatePlaneZone(tt.epsg)
+			if !ok {
+				t.Fatalf("zone %d is not supported", tt.epsg)
+			}
+
+			got := z.ToPoint(tt.x, tt.y)
+			if d := Distance(got, tt.want); d > within {
+				t.Errorf("got %v, %.2fm from %v", got, d, tt.want)
+			}
+		})
+	}
+}
+
+// TestStatePlaneRoundTrip projects landmarks away from the
+// zones' origins forward, and expects ToPoint to invert them
+func TestStatePlaneRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		epsg int
+		at   Point
+	}{
+		{"Eureka", 2225, Point{40.8021, -124.1637}},
+		{"Sacramento", 2226, Point{38.5816, -121.4944}},
+		{"Fresno", 2228, Point{36.7378, -119.7871}},
+		{"Los Angeles City Hall", 2229, Point{34.0537, -118.2428}},
+		{"San Diego", 2230, Point{32.7157, -117.1611}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z, _ := StatePlaneZone(tt.epsg)
+			x, y := project(z, float64(tt.at.Lat), float64(tt.at.Lng))
+
+			got := z.ToPoint(x, y)
+			if d := Distance(got, tt.at); d > within {
+				t.Errorf("got %v, %.2fm from %v", got, d, tt.at)
+			}
+		})
+	}
+}
+
+func TestStatePlaneZoneUnknown(t *testing.T) {
+	if _, ok := StatePlaneZone(4326); ok {
+		t.Error("expected EPSG 4326 not to be a State Plane zone")
+	}
+}
+
+// project is the forward Lambert Conformal Conic projection (Snyder,
+// Map Projections: A Working Manual, 15-1 to 15-10), returning feet
+func project(z StatePlane, lat, lng float64) (x, y float64) {
+	a := grs80SemiMajorAxis
+	e := math.Sqrt(2*grs80Flattening - grs80Flattening*grs80Flattening)
+
+	phi1, phi2 := radians(z.lat1), radians(z.lat2)
+	m1, m2 := lccM(phi1, e), lccM(phi2, e)
+	t1, t2 := lccT(phi1, e), lccT(phi2, e)
+
+	n := (math.Log(m1) - math.Log(m2)) / (math.Log(t1) - math.Log(t2))
+	f := m1 / (n * math.Pow(t1, n))
+	r0 := a * f * math.Pow(lccT(radians(z.lat0), e), n)
+	r := a * f * math.Pow(lccT(radians(lat), e), n)
+	theta := n * radians(lng-z.lon0)
+
+	x = z.falseEasting + r*math.Sin(theta)/usSurveyFoot
+	y = z.falseNorthing + (r0-r*math.Cos(theta))/usSurveyFoot
+	return x, y
+}
@@ -2,15 +2,11 @@ package recommender
 
 import (
 	"context"
-	"math"
 	"net/http"
 
-	"github.com/parkerduckworth/lonchera/app/config"
 	"github.com/parkerduckworth/lonchera/failure"
-	"github.com/parkerduckworth/lonchera/recommender/schema"
-	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
-	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/filters"
-	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/parkerduckworth/lonchera/recommender/geo"
+	"github.com/parkerduckworth/lonchera/recommender/store"
 )
 
 const (
@@ -23,91 +19,81 @@ type GeoCoordinates struct {
 	MaxDistance float32 `json:"maxMetersAway"`
 }
 
-func ByLocation(ctx context.Context, coord *filters.GeoCoordinatesParameter, limit int) (interface{}, *failure.Error) {
-	client := weaviate.New(config.Conf.Weaviate)
-
-	fields := []graphql.Field{
-		{Name: schema.PropName},
-		{Name: schema.PropFacilityType},
-		{Name: schema.PropFoodItems},
-		{Name: schema.PropLocation, Fields: []graphql.Field{
-			{Name: schema.PropLocationLatitude},
-			{Name: schema.PropLocationLongitude},
-		}},
+func (coord *GeoCoordinates) toGeoRange() store.GeoRange {
+	return store.GeoRange{
+		Latitude:    coord.Latitude,
+		Longitude:   coord.Longitude,
+		MaxDistance: coord.MaxDistance,
 	}
+}
 
-	where := filters.Where().
-		WithOperator(filters.WithinGeoRange).
-		WithPath([]string{schema.PropLocation}).
-		WithValueGeoRange(coord)
-
-	result, err := client.GraphQL().Get().
-		WithClassName(schema.ClassName).
-		WithFields(fields...).
-		WithWhere(where).
-		WithLimit(limit).
-		Do(ctx)
-
-	err = checkWeaviateResponse(result, err)
-	if err != nil {
-		return nil, failure.NewError(
-			http.StatusInternalServerError, ErrFailedToRecommendByLocation, err)
-	}
+// ByLocation recommends the trucks closest to the given coordinates,
+// nearest first. If coord.MaxDistance is zero the search is unbounded,
+// and the closest limit trucks are returned however far away they are.
+// The returned Meta reports the dataset version
+func ByLocation(ctx context.Context, coord *GeoCoordinates, filter Filter, limit int) (*Response, *Meta, *failure.Error) {
+	trucks, err := store.Nearest(ctx, backend, store.GeoQuery{
+		GeoRange: coord.toGeoRange(),
+		Filter:   filter.toStore(),
+		Limit:    limit,
+	})
 
-	resp, err := buildResponse(result)
 	if err != nil {
-		return nil, failure.NewError(
+		return nil, nil, failure.NewError(
 			http.StatusInternalServerError, ErrFailedToRecommendByLocation, err)
 	}
 
+	resp := buildResponse(trucks, filter)
 	insertDistances(coord, resp)
-	return resp, nil
+	return resp, newMeta(ctx, 0, limit, filter), nil
 }
 
-type geoPoints struct {
-	src geoPoint
-	dst geoPoint
-}
+func insertDistances(coord *GeoCoordinates, resp *Response) {
+	src := geo.Point{Lat: coord.Latitude, Lng: coord.Longitude}
 
-type geoPoint struct {
-	lat float32
-	lng float32
-}
-
-func insertDistances(coord *filters.GeoCoordinatesParameter, resp *Response) {
 	for _, res := range *resp {
-		if res.Location.Latitude != 0 && res.Location.Longitude != 0 {
-			metersAway, milesAway := calculateGeoDistance(geoPoints{
-				src: geoPoint{lat: coord.Latitude, lng: coord.Longitude},
-				dst: geoPoint{res.Location.Latitude, res.Location.Longitude},
-			})
-
-			res.Location.MetersAway = metersAway
-			res.Location.MilesAway = milesAway
+		if res.Location == nil {
+			continue
 		}
+
+		dst := geo.Point{Lat: res.Location.Latitude, Lng: res.Location.Longitude}
+		metersAway, milesAway := calculateGeoDistance(src, dst)
+
+		res.Location.MetersAway = metersAway
+		res.Location.MilesAway = milesAway
 	}
 }
 
-// calculateGeoDistance uses the haversine distance formula
-// to calculate the number of meters/miles each result is
-// from the input location
-func calculateGeoDistance(pts geoPoints) (metersAway, milesAway float32) {
-	radiusMeters := 6371e3
+// calculateGeoDistance calculates the number of meters/miles
+// each result is from the input location
+func calculateGeoDistance(src, dst geo.Point) (metersAway, milesAway float32) {
+	distMeters := geo.Distance(src, dst)
+	distMiles := geo.MetersToMiles(distMeters)
 
-	φ1 := float64(pts.src.lat * math.Pi / 180) // φ, λ in radians
-	φ2 := float64(pts.dst.lat * math.Pi / 180)
+	return float32(distMeters), float32(distMiles)
+}
 
-	Δφ := float64((pts.dst.lat - pts.src.lat) * math.Pi / 180)
-	Δλ := float64((pts.dst.lng - pts.src.lng) * math.Pi / 180)
+// ByLocationPage is ByLocation, a page at a time
+func ByLocationPage(ctx context.Context, coord *GeoCoordinates, filter Filter, page Page) (*PagedResponse, *Meta, *failure.Error) {
+	cur, ferr := page.open(ctx, queryKey("location", *coord, filter), &filter)
+	if ferr != nil {
+		return nil, nil, ferr
+	}
 
-	a := math.Sin(Δφ/2)*math.Sin(Δφ/2) +
-		math.Cos(φ1)*math.Cos(φ2)*
-			math.Sin(Δλ/2)*math.Sin(Δλ/2)
+	trucks, err := store.Nearest(ctx, backend, store.GeoQuery{
+		GeoRange: coord.toGeoRange(),
+		Filter:   filter.toStore(),
+		Limit:    cur.limit(),
+	})
 
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	if err != nil {
+		return nil, nil, failure.NewError(
+			http.StatusInternalServerError, ErrFailedToRecommendByLocation, err)
+	}
 
-	distMeters := radiusMeters * c
-	distMiles := distMeters * 0.00062137
+	trucks, next := cur.cut(trucks)
 
-	return float32(distMeters), float32(distMiles)
+	resp := buildResponse(trucks, filter)
+	insertDistances(coord, resp)
+	return newPagedResponse(resp, next), newMeta(ctx, 0, cur.Size, filter), nil
 }
@@ -0,0 +1,159 @@
+package recommender
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+// MaxPageDepth bounds how far results can be paged through. Each page
+// is cut from a query for every result up to it. Stores order results
+// the same way whatever the limit, so a page continues the one before
+// it as long as the dataset is unchanged, by an import or a write
+const MaxPageDepth = 1000
+
+const (
+	ErrInvalidCursor  = "invalid cursor"
+	ErrCursorMismatch = "cursor was issued for another query"
+	ErrCursorExpired  = "the dataset has changed since the first page, restart without a cursor"
+)
+
+// Page selects a page of results. Cursor is empty for the first page,
+// and Size may be zero on later pages to keep the size of the first
+type Page struct {
+	Size   int
+	Cursor string
+}
+
+// PagedResponse is a page of results. NextCursor requests the page
+// after it, and is empty when HasMore is false
+type PagedResponse struct {
+	Data       *Response `json:"data"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+	HasMore    bool      `json:"hasMore"`
+}
+
+// cursor is the state carried from one page to the next. Everything
+// which decides the order of results is pinned by the first page, so
+// later pages neither repeat nor skip results
+type cursor struct {
+	Offset int `json:"o"`
+	Size   int `json:"s"`
+
+	// Query identifies the query the cursor was issued for, and
+	// Version and Revision the dataset it was issued against
+	Query    string `json:"q"`
+	Version  string `json:"v,omitempty"`
+	Revision int    `json:"r,omitempty"`
+
+	// Certainty is the threshold the first page was found with,
+	// and OpenAt the time trucks were checked to be open at
+	Certainty float32   `json:"c,omitempty"`
+	OpenAt    time.Time `json:"t"`
+}
+
+// queryKey identifies a query by its parameters
+func queryKey(params ...interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", params)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// open returns the cursor of the page. For the first page it is a new
+// cursor, otherwise the request's cursor, once checked to belong to the
+// query and to the current dataset. The filter's time is pinned to the
+// cursor's in either case
+func (p Page) open(ctx context.Context, key string, filter *Filter) (*cursor, *failure.Error) {
+	version, revision := DatasetVersion(ctx), DatasetRevision(ctx)
+
+	if p.Cursor == "" {
+		filter.OpenAt = filter.openAt()
+		return &cursor{
+			Size:     p.Size,
+			Query:    key,
+			Version:  version,
+			Revision: revision,
+			OpenAt:   filter.OpenAt,
+		}, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, failure.NewError(http.StatusBadRequest, ErrInvalidCursor, err)
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Offset < 0 || c.Size < 1 {
+		return nil, failure.NewError(http.StatusBadRequest, ErrInvalidCursor, err)
+	}
+
+	if c.Query != key {
+		return nil, failure.NewError(http.StatusBadRequest, ErrCursorMismatch, nil)
+	}
+	if c.Version != version || c.Revision != revision {
+		return nil, failure.NewError(http.StatusConflict, ErrCursorExpired, nil)
+	}
+
+	if p.Size > 0 {
+		c.Size = p.Size
+	}
+	filter.OpenAt = c.OpenAt
+
+	return &c, nil
+}
+
+// end is the number of results up to the end of the page
+func (c *cursor) end() int {
+	end := c.Offset + c.Size
+	if end > MaxPageDepth {
+		end = MaxPageDepth
+	}
+	return end
+}
+
+// limit is the number of results to query for the page: every result
+// up to the end of the page, and one more to tell if there are more
+func (c *cursor) limit() int {
+	return c.end() + 1
+}
+
+// cut returns the page's trucks, out of every truck up to it,
+// and the cursor of the next page, which is empty if there is none
+func (c *cursor) cut(trucks []store.Truck) ([]store.Truck, string) {
+	end := c.end()
+
+	if c.Offset >= len(trucks) {
+		return nil, ""
+	}
+
+	more := len(trucks) > end && end < MaxPageDepth
+	if end > len(trucks) {
+		end = len(trucks)
+	}
+	page := trucks[c.Offset:end]
+
+	if !more {
+		return page, ""
+	}
+
+	next := *c
+	next.Offset = end
+
+	b, _ := json.Marshal(next)
+	return page, base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newPagedResponse(data *Response, next string) *PagedResponse {
+	return &PagedResponse{
+		Data:       data,
+		NextCursor: next,
+		HasMore:    next != "",
+	}
+}
@@ -0,0 +1,86 @@
+package recommender
+
+import (
+	"math"
+	"sort"
+
+	"github.com/parkerduckworth/lonchera/app/config"
+)
+
+// Decay curves which may be used to turn distance into a proximity score
+const (
+	DecayLinear      = "linear"
+	DecayExponential = "exponential"
+)
+
+// rerankCandidates is the multiple of the requested limit which is fetched
+// before ranking, so that close but slightly less relevant trucks can
+// still make it into the final results
+const rerankCandidates = 5
+
+// Weights configures how relevance and distance are blended into
+// the final ranking of combined fare and location recommendations
+type Weights struct {
+	// Relevance is the weight given to the certainty of the answer
+	Relevance float32
+
+	// Distance is the weight given to the proximity of the truck
+	Distance float32
+
+	// Decay is the curve used to turn distance into proximity
+	Decay string
+
+	// DecayMiles is the distance scale of the decay curve. For linear decay,
+	// proximity falls to zero at DecayMiles. For exponential decay,
+	// proximity halves every DecayMiles
+	DecayMiles float32
+}
+
+// DefaultWeights returns the ranking weights set in the application configuration
+func DefaultWeights() Weights {
+	return Weights{
+		Relevance:  config.Conf.Ranking.RelevanceWeight,
+		Distance:   config.Conf.Ranking.DistanceWeight,
+		Decay:      config.Conf.Ranking.Decay,
+		DecayMiles: config.Conf.Ranking.DecayMiles,
+	}
+}
+
+// proximity scores a distance between 0 and 1, where 1 is right here
+func (w Weights) proximity(miles float32) float32 {
+	if w.DecayMiles <= 0 {
+		return 0
+	}
+
+	switch w.Decay {
+	case DecayLinear:
+		return float32(math.Max(0, float64(1-miles/w.DecayMiles)))
+	default:
+		return float32(math.Pow(0.5, float64(miles/w.DecayMiles)))
+	}
+}
+
+// rank scores each result by its weighted relevance and
+// proximity, then sorts the results best first
+func rank(resp *Response, w Weights) {
+	total := w.Relevance + w.Distance
+
+	for i := range *resp {
+		res := &(*resp)[i]
+		scores := &ResultScores{Relevance: res.Certainty}
+		if res.Location != nil {
+			scores.Proximity = w.proximity(res.Location.MilesAway)
+		}
+
+		scores.Final = scores.Relevance
+		if total > 0 {
+			scores.Final = (w.Relevance*scores.Relevance + w.Distance*scores.Proximity) / total
+		}
+
+		res.Scores = scores
+	}
+
+	sort.SliceStable(*resp, func(i, j int) bool {
+		return (*resp)[i].Scores.Final > (*resp)[j].Scores.Final
+	})
+}
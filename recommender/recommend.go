@@ -0,0 +1,44 @@
+package recommender
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+const (
+	ErrFailedToRecommend = "failed to recommend by fare and location"
+)
+
+// Recommend combines fare and location recommendations, answering
+// the question using only the trucks within range of coord. Results
+// carry their certainty, their distance from coord, and the scores
+// used to rank them according to weights. The returned Meta reports
+// the certainty threshold that was used
+func Recommend(ctx context.Context, question string, coord *GeoCoordinates, weights Weights, certainty Certainty, filter Filter, limit int) (*Response, *Meta, *failure.Error) {
+	within := coord.toGeoRange()
+
+	trucks, used, err := askWithRelaxation(ctx, store.AskQuery{
+		Question: question,
+		Within:   &within,
+		Filter:   filter.toStore(),
+		Limit:    limit * rerankCandidates,
+	}, certainty, limit)
+
+	if err != nil {
+		return nil, nil, failure.NewError(
+			http.StatusInternalServerError, ErrFailedToRecommend, err)
+	}
+
+	resp := buildResponse(trucks, filter)
+	insertDistances(coord, resp)
+	rank(resp, weights)
+
+	if len(*resp) > limit {
+		*resp = (*resp)[:limit]
+	}
+
+	return resp, newMeta(ctx, used, limit, filter), nil
+}
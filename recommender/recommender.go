@@ -0,0 +1,104 @@
+// Package recommender contains the business logic for recommending
+// mobile food vendors. Vendors are read from a store.Store, which is
+// selected at startup by the application configuration.
+package recommender
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+var backend store.Store
+
+// Setup builds the storage backend named in the application
+// configuration. Otherwise this function kills the running
+// process if the backend cannot be loaded
+func Setup() {
+	switch config.Conf.Store.Backend {
+	case config.StoreMemory:
+		var mapping *permit.Mapping
+		if config.Conf.Store.MappingPath != "" {
+			var err error
+			if mapping, err = permit.LoadMapping(config.Conf.Store.MappingPath); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		mem, err := store.LoadMemory(config.Conf.Store.CSVPath, mapping)
+		if err != nil {
+			log.Fatalf("failed to load in-memory store: %s", err)
+		}
+		backend = mem
+	default:
+		backend = store.NewWeaviate(config.Conf.Weaviate)
+	}
+
+	if config.Conf.Store.Replica.Enabled && config.Conf.Store.Backend != config.StoreMemory {
+		replica := store.NewReplica(backend,
+			config.Conf.Store.Replica.RefreshInterval,
+			config.Conf.Store.Replica.StaleAfter)
+
+		replica.Start(context.Background())
+		backend = replica
+	}
+}
+
+// DatasetVersion returns the version of the dataset being served,
+// or an empty string if the store's dataset is not versioned
+func DatasetVersion(ctx context.Context) string {
+	v, ok := backend.(store.Versioned)
+	if !ok {
+		return ""
+	}
+
+	version, err := v.Version(ctx)
+	if err != nil {
+		log.Warnf("failed to read dataset version: %s", err)
+	}
+	return version
+}
+
+// DatasetRevision returns the number of writes made to the dataset
+// being served since it was activated, or zero if the store's
+// dataset can't be written to
+func DatasetRevision(ctx context.Context) int {
+	r, ok := backend.(store.Revisioned)
+	if !ok {
+		return 0
+	}
+
+	revision, err := r.Revision(ctx)
+	if err != nil {
+		log.Warnf("failed to read dataset revision: %s", err)
+	}
+	return revision
+}
+
+// CheckSchema returns an error if the store's dataset was
+// created with a schema this server can't query
+func CheckSchema(ctx context.Context) error {
+	c, ok := backend.(store.SchemaChecker)
+	if !ok {
+		return nil
+	}
+
+	if err := c.CheckSchema(ctx); err != nil {
+		return fmt.Errorf("incompatible schema: %s", err)
+	}
+	return nil
+}
+
+// SetStore replaces the storage backend, allowing callers
+// such as tests to run the recommender against a store.Memory
+func SetStore(s store.Store) {
+	backend = s
+
+	cityCache.Lock()
+	cityCache.cities = nil
+	cityCache.Unlock()
+}
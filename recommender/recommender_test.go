@@ -0,0 +1,651 @@
+package recommender
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/app/config"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+// center is where location queries are made from
+var mem *store.Memory
+
+var center = GeoCoordinates{Latitude: 37.7749, Longitude: -122.4194}
+
+// monday noon is when the lunch trucks are open, and the dinner trucks closed
+var mondayNoon = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+// validUntil is when the permits expire, but for the lapsed ones
+var (
+	validUntil = time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	lapsedAt   = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// trucks are north of center, a thousandth of a degree being about 111m.
+// The two closest trucks' permits have expired, one by its status and
+// the other by its date, and the last truck can't be located
+var trucks = []struct {
+	name, fare, hours, status string
+	north                     float32
+	lapsed                    bool
+}{
+	{"Near Tacos", "Tacos: Quesadillas", "Mo-Su:10AM-2PM", permit.StatusApproved, 0.001, false},
+	{"Burger Barn", "Burgers: Fries", "", permit.StatusApproved, 0.004, false},
+	{"Curry Cart", "Curry: Rice", "Mo-Su:6PM-10PM", permit.StatusIssued, 0.008, false},
+	{"Far Tacos", "Tacos: Burritos", "Mo-Su:6PM-10PM", permit.StatusApproved, 0.027, false},
+	{"Expired Eats", "Tacos: Burritos", "", permit.StatusExpired, 0.0005, false},
+	{"Lapsed Burritos", "Burritos", "", permit.StatusApproved, 0.0007, true},
+	{"Nowhere Burritos", "Burritos", "", permit.StatusApproved, 0, false},
+}
+
+func TestMain(m *testing.M) {
+	config.Conf.TimeZone = "UTC"
+	config.Conf.Cities = map[string]struct{ TimeZone string }{
+		"new-york": {TimeZone: "America/New_York"},
+	}
+	config.Conf.Logger.Level = "ERROR"
+	config.Conf.Ask.MinCertainty = 0.3
+	config.Conf.Ask.RelaxFloor = 0.1
+	config.Conf.Ask.RelaxStep = 0.1
+	config.Conf.Permits.ActiveStatuses = []string{permit.StatusApproved, permit.StatusIssued}
+	config.Conf.Ranking.RelevanceWeight = 0.7
+	config.Conf.Ranking.DistanceWeight = 0.3
+	config.Conf.Ranking.Decay = DecayExponential
+	config.Conf.Ranking.DecayMiles = 0.5
+	log.Setup()
+
+	recs := make([]permit.Record, len(trucks))
+	for i, t := range trucks {
+		open, err := permit.ParseDaysHours(t.hours)
+		if err != nil {
+			panic(err)
+		}
+
+		recs[i] = permit.Record{
+			City:           permit.DefaultCity,
+			LocationID:     t.name,
+			Applicant:      t.name,
+			FacilityType:   "Truck",
+			FoodItems:      t.fare,
+			Status:         t.status,
+			ExpirationDate: validUntil,
+			DaysHours:      t.hours,
+			OpenHours:      open,
+		}
+		if t.lapsed {
+			recs[i].ExpirationDate = lapsedAt
+		}
+		if t.north != 0 {
+			recs[i].Latitude = center.Latitude + t.north
+			recs[i].Longitude = center.Longitude
+			recs[i].LocationSource = permit.LocationSourceDataset
+		}
+	}
+	mem = store.NewMemory(recs)
+	SetStore(mem)
+
+	os.Exit(m.Run())
+}
+
+// names returns the names of the results, in order
+func names(resp *Response) []string {
+	var out []string
+	for _, res := range *resp {
+		out = append(out, res.Name)
+	}
+	return out
+}
+
+func checkNames(t *testing.T, resp *Response, want ...string) {
+	t.Helper()
+
+	got := names(resp)
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestByFare(t *testing.T) {
+	ctx := context.Background()
+
+	resp, meta, ferr := ByFare(ctx, "where can i get tacos and burritos?", DefaultCertainty(), Filter{}, 10)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+
+	// the expired permit is left out, and the unlocated truck is kept
+	checkNames(t, resp, "Far Tacos", "Near Tacos", "Nowhere Burritos")
+
+	best := (*resp)[0]
+	if best.Certainty != 1 {
+		t.Errorf("certainty = %v, want 1", best.Certainty)
+	}
+	if best.Answer == nil || best.Answer.Text != "Tacos" {
+		t.Errorf("answer = %+v, want Tacos", best.Answer)
+	}
+	if (*resp)[2].Location != nil {
+		t.Errorf("unlocated truck has location %+v", (*resp)[2].Location)
+	}
+
+	if meta.Certainty != config.Conf.Ask.MinCertainty || meta.Limit != 10 {
+		t.Errorf("meta = %+v, want the default certainty and a limit of 10", meta)
+	}
+
+	resp, _, ferr = ByFare(ctx, "tacos burritos", DefaultCertainty(), Filter{}, 1)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+	checkNames(t, resp, "Far Tacos")
+}
+
+func TestByFareIncludeInactive(t *testing.T) {
+	resp, _, ferr := ByFare(context.Background(), "tacos burritos",
+		DefaultCertainty(), Filter{IncludeInactive: true}, 10)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+
+	checkNames(t, resp, "Far Tacos", "Expired Eats", "Near Tacos", "Lapsed Burritos", "Nowhere Burritos")
+}
+
+func TestByFareIgnoreExpiration(t *testing.T) {
+	config.Conf.Permits.IgnoreExpiration = true
+	defer func() { config.Conf.Permits.IgnoreExpiration = false }()
+
+	resp, _, ferr := ByFare(context.Background(), "tacos burritos", DefaultCertainty(), Filter{}, 10)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+
+	// the lapsed permit's status is still active, the expired one's isn't
+	checkNames(t, resp, "Far Tacos", "Near Tacos", "Lapsed Burritos", "Nowhere Burritos")
+}
+
+func TestCertaintyRelaxation(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		certainty Certainty
+		limit     int
+		want      []string
+		used      float32
+	}{
+		{
+			name:      "strict",
+			certainty: Certainty{Min: 0.9},
+			limit:     2,
+			want:      []string{"Far Tacos"},
+			used:      0.9,
+		},
+		{
+			name:      "relaxed until enough are found",
+			certainty: Certainty{Min: 0.9, Relax: true},
+			limit:     2,
+			want:      []string{"Far Tacos", "Near Tacos"},
+			used:      0.5,
+		},
+		{
+			name:      "not relaxed when enough are found",
+			certainty: Certainty{Min: 0.9, Relax: true},
+			limit:     1,
+			want:      []string{"Far Tacos"},
+			used:      0.9,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, meta, ferr := ByFare(ctx, "tacos burritos", tt.certainty, Filter{}, tt.limit)
+			if ferr != nil {
+				t.Fatal(ferr)
+			}
+
+			checkNames(t, resp, tt.want...)
+			if diff := meta.Certainty - tt.used; diff > 1e-6 || diff < -1e-6 {
+				t.Errorf("certainty = %v, want %v", meta.Certainty, tt.used)
+			}
+		})
+	}
+}
+
+func TestByLocation(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		maxDistance float32
+		limit       int
+		want        []string
+	}{
+		{
+			name:  "nearest first",
+			limit: 10,
+			want:  []string{"Near Tacos", "Burger Barn", "Curry Cart", "Far Tacos"},
+		},
+		{
+			name:  "k nearest",
+			limit: 2,
+			want:  []string{"Near Tacos", "Burger Barn"},
+		},
+		{
+			name:        "within range",
+			maxDistance: 1000,
+			limit:       10,
+			want:        []string{"Near Tacos", "Burger Barn", "Curry Cart"},
+		},
+		{
+			name:        "k nearest within range",
+			maxDistance: 1000,
+			limit:       1,
+			want:        []string{"Near Tacos"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coord := center
+			coord.MaxDistance = tt.maxDistance
+
+			resp, _, ferr := ByLocation(ctx, &coord, Filter{}, tt.limit)
+			if ferr != nil {
+				t.Fatal(ferr)
+			}
+
+			checkNames(t, resp, tt.want...)
+
+			var last float32
+			for _, res := range *resp {
+				if res.Location == nil || res.Location.MetersAway < last {
+					t.Fatalf("results are not nearest first: %+v", res.Location)
+				}
+				last = res.Location.MetersAway
+			}
+		})
+	}
+}
+
+func TestRecommendWeights(t *testing.T) {
+	ctx := context.Background()
+	coord := center
+	coord.MaxDistance = 5000
+
+	tests := []struct {
+		name    string
+		weights Weights
+		want    []string
+	}{
+		{
+			// the far truck answers the whole question, which
+			// outweighs the near one by default
+			name:    "default",
+			weights: DefaultWeights(),
+			want:    []string{"Far Tacos", "Near Tacos"},
+		},
+		{
+			name:    "relevance only",
+			weights: Weights{Relevance: 1, Decay: DecayExponential, DecayMiles: 0.5},
+			want:    []string{"Far Tacos", "Near Tacos"},
+		},
+		{
+			name:    "distance only",
+			weights: Weights{Distance: 1, Decay: DecayExponential, DecayMiles: 0.5},
+			want:    []string{"Near Tacos", "Far Tacos"},
+		},
+		{
+			name:    "distance first, linear",
+			weights: Weights{Relevance: 0.3, Distance: 0.7, Decay: DecayLinear, DecayMiles: 1},
+			want:    []string{"Near Tacos", "Far Tacos"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _, ferr := Recommend(ctx, "tacos burritos", &coord, tt.weights, DefaultCertainty(), Filter{}, 10)
+			if ferr != nil {
+				t.Fatal(ferr)
+			}
+
+			checkNames(t, resp, tt.want...)
+
+			for i, res := range *resp {
+				if res.Scores == nil {
+					t.Fatalf("%s has no scores", res.Name)
+				}
+				if i > 0 && res.Scores.Final > (*resp)[i-1].Scores.Final {
+					t.Errorf("%s outscores the result before it", res.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestRecommendRelaxesToLimit(t *testing.T) {
+	coord := center
+	coord.MaxDistance = 5000
+
+	// one truck answers the whole question, which is all that was
+	// asked for, so the threshold isn't relaxed to fill the candidates
+	resp, meta, ferr := Recommend(context.Background(), "tacos burritos", &coord,
+		DefaultWeights(), Certainty{Min: 0.9, Relax: true}, Filter{}, 1)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+
+	checkNames(t, resp, "Far Tacos")
+	if meta.Certainty != 0.9 {
+		t.Errorf("certainty = %v, want 0.9", meta.Certainty)
+	}
+}
+
+func TestOpenHours(t *testing.T) {
+	ctx := context.Background()
+
+	resp, _, ferr := ByLocation(ctx, &center, Filter{OpenAt: mondayNoon}, 10)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+
+	want := map[string]*bool{
+		"Near Tacos":  boolPtr(true),
+		"Burger Barn": nil,
+		"Curry Cart":  boolPtr(false),
+		"Far Tacos":   boolPtr(false),
+	}
+	for _, res := range *resp {
+		w, got := want[res.Name], res.Open
+		if (w == nil) != (got == nil) || (w != nil && *w != *got) {
+			t.Errorf("%s: open = %v, want %v", res.Name, fmtBool(got), fmtBool(w))
+		}
+	}
+
+	resp, _, ferr = ByLocation(ctx, &center, Filter{OpenAt: mondayNoon, OpenOnly: true}, 10)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+	checkNames(t, resp, "Near Tacos")
+
+	evening := mondayNoon.Add(7 * time.Hour)
+	resp, _, ferr = ByFare(ctx, "tacos", DefaultCertainty(), Filter{OpenAt: evening, OpenOnly: true}, 10)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+	checkNames(t, resp, "Far Tacos")
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func fmtBool(b *bool) interface{} {
+	if b == nil {
+		return "unknown"
+	}
+	return *b
+}
+
+func TestByLocationPage(t *testing.T) {
+	ctx := context.Background()
+
+	all, _, ferr := ByLocation(ctx, &center, Filter{}, 10)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+
+	var paged []string
+	page := Page{Size: 3}
+	for i := 0; ; i++ {
+		resp, meta, ferr := ByLocationPage(ctx, &center, Filter{}, page)
+		if ferr != nil {
+			t.Fatal(ferr)
+		}
+		if meta.Limit != 3 {
+			t.Errorf("page %d: limit = %d, want 3", i, meta.Limit)
+		}
+
+		paged = append(paged, names(resp.Data)...)
+		if resp.HasMore != (resp.NextCursor != "") {
+			t.Fatalf("page %d: hasMore = %v with cursor %q", i, resp.HasMore, resp.NextCursor)
+		}
+		if !resp.HasMore {
+			break
+		}
+		if i > len(*all) {
+			t.Fatal("pages never end")
+		}
+
+		// later pages keep the size of the first
+		page = Page{Cursor: resp.NextCursor}
+	}
+
+	want := names(all)
+	if len(paged) != len(want) {
+		t.Fatalf("paged through %q, want %q", paged, want)
+	}
+	for i := range want {
+		if paged[i] != want[i] {
+			t.Fatalf("paged through %q, want %q", paged, want)
+		}
+	}
+}
+
+func TestByFarePage(t *testing.T) {
+	ctx := context.Background()
+	certainty := Certainty{Min: 0.9, Relax: true}
+
+	first, meta, ferr := ByFarePage(ctx, "tacos burritos", certainty, Filter{}, Page{Size: 2})
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+	checkNames(t, first.Data, "Far Tacos", "Near Tacos")
+	if !first.HasMore {
+		t.Fatal("first page has no more results")
+	}
+
+	// the threshold relaxed for the first page is kept for the next
+	next, nextMeta, ferr := ByFarePage(ctx, "tacos burritos", certainty, Filter{}, Page{Cursor: first.NextCursor})
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+	checkNames(t, next.Data, "Nowhere Burritos")
+	if next.HasMore {
+		t.Error("last page has more results")
+	}
+	if nextMeta.Certainty != meta.Certainty {
+		t.Errorf("certainty = %v, want the first page's %v", nextMeta.Certainty, meta.Certainty)
+	}
+}
+
+func TestPageCursorErrors(t *testing.T) {
+	ctx := context.Background()
+
+	first, _, ferr := ByLocationPage(ctx, &center, Filter{}, Page{Size: 1})
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+
+	moved := center
+	moved.Latitude += 0.01
+
+	tests := []struct {
+		name   string
+		coord  GeoCoordinates
+		cursor string
+		status int
+		msg    string
+	}{
+		{"malformed", center, "not a cursor!", http.StatusBadRequest, ErrInvalidCursor},
+		{"other query", moved, first.NextCursor, http.StatusBadRequest, ErrCursorMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, ferr := ByLocationPage(ctx, &tt.coord, Filter{}, Page{Cursor: tt.cursor})
+			if ferr == nil || ferr.StatusCode != tt.status || ferr.Message != tt.msg {
+				t.Errorf("error = %+v, want %d %q", ferr, tt.status, tt.msg)
+			}
+		})
+	}
+}
+
+// revisioned is the in-memory store, as if it were written to
+type revisioned struct {
+	*store.Memory
+	revision int
+	reads    int
+}
+
+func (r *revisioned) Revision(context.Context) (int, error) {
+	return r.revision, nil
+}
+
+// All counts the reads of every truck
+func (r *revisioned) All(ctx context.Context) ([]store.Truck, error) {
+	r.reads++
+	return r.Memory.All(ctx)
+}
+
+func TestPageCursorExpiresOnWrite(t *testing.T) {
+	ctx := context.Background()
+
+	s := &revisioned{Memory: mem}
+	SetStore(s)
+	defer SetStore(mem)
+
+	first, _, ferr := ByLocationPage(ctx, &center, Filter{}, Page{Size: 1})
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+
+	if _, _, ferr := ByLocationPage(ctx, &center, Filter{}, Page{Cursor: first.NextCursor}); ferr != nil {
+		t.Fatalf("unchanged dataset: %+v", ferr)
+	}
+
+	s.revision++
+
+	_, _, ferr = ByLocationPage(ctx, &center, Filter{}, Page{Cursor: first.NextCursor})
+	if ferr == nil || ferr.StatusCode != http.StatusConflict || ferr.Message != ErrCursorExpired {
+		t.Errorf("error = %+v, want %d %q", ferr, http.StatusConflict, ErrCursorExpired)
+	}
+}
+
+func TestCities(t *testing.T) {
+	ctx := context.Background()
+
+	s := &revisioned{Memory: mem}
+	SetStore(s)
+	defer SetStore(mem)
+
+	for i := 0; i < 2; i++ {
+		cities, ferr := Cities(ctx)
+		if ferr != nil {
+			t.Fatal(ferr)
+		}
+
+		// every truck is counted, and the unlocated one is left out of the bounds
+		if len(cities) != 1 || cities[0].Name != permit.DefaultCity || cities[0].Trucks != len(trucks) {
+			t.Fatalf("cities = %+v, want every truck in %s", cities, permit.DefaultCity)
+		}
+		if b := cities[0].Bounds; b == nil || b.MinLatitude != center.Latitude+0.0005 || b.MaxLatitude != center.Latitude+0.027 {
+			t.Errorf("bounds = %+v, want the located trucks'", b)
+		}
+	}
+	if s.reads != 1 {
+		t.Errorf("trucks read %d times, want once until the dataset changes", s.reads)
+	}
+
+	s.revision++
+	if _, ferr := Cities(ctx); ferr != nil {
+		t.Fatal(ferr)
+	}
+	if s.reads != 2 {
+		t.Errorf("trucks read %d times, want again once the dataset changed", s.reads)
+	}
+}
+
+func TestOpenHoursByCity(t *testing.T) {
+	ctx := context.Background()
+
+	// every truck's hours are in its own city's local time, and
+	// monday noon in UTC, the default time zone, is 8AM in new-york
+	var recs []permit.Record
+	for _, r := range []struct{ name, city, hours string }{
+		{"Lunch Tacos", permit.DefaultCity, "Mo-Su:10AM-2PM"},
+		{"Lunch Tacos NY", "new-york", "Mo-Su:10AM-2PM"},
+		{"Breakfast Tacos NY", "new-york", "Mo-Su:7AM-9AM"},
+	} {
+		open, err := permit.ParseDaysHours(r.hours)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, permit.Record{
+			City:           r.city,
+			LocationID:     r.name,
+			Applicant:      r.name,
+			FacilityType:   "Truck",
+			FoodItems:      "Tacos",
+			Status:         permit.StatusApproved,
+			ExpirationDate: validUntil,
+			DaysHours:      r.hours,
+			OpenHours:      open,
+		})
+	}
+
+	SetStore(store.NewMemory(recs))
+	defer SetStore(mem)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   map[string]bool
+	}{
+		{
+			name:   "every city",
+			filter: Filter{OpenAt: mondayNoon},
+			want:   map[string]bool{"Lunch Tacos": true, "Lunch Tacos NY": false, "Breakfast Tacos NY": true},
+		},
+		{
+			name:   "open only",
+			filter: Filter{OpenAt: mondayNoon, OpenOnly: true},
+			want:   map[string]bool{"Lunch Tacos": true, "Breakfast Tacos NY": true},
+		},
+		{
+			name:   "open only in one city",
+			filter: Filter{City: "new-york", OpenAt: mondayNoon, OpenOnly: true},
+			want:   map[string]bool{"Breakfast Tacos NY": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _, ferr := ByFare(ctx, "tacos", DefaultCertainty(), tt.filter, 10)
+			if ferr != nil {
+				t.Fatal(ferr)
+			}
+
+			got := make(map[string]bool, len(*resp))
+			for _, res := range *resp {
+				got[res.Name] = res.Open != nil && *res.Open
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for name, open := range tt.want {
+				if g, ok := got[name]; !ok || g != open {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,84 +1,141 @@
 package recommender
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"time"
 
-	"github.com/parkerduckworth/lonchera/failure"
-	"github.com/semi-technologies/weaviate/entities/models"
+	"github.com/parkerduckworth/lonchera/recommender/geo"
+	"github.com/parkerduckworth/lonchera/recommender/store"
 )
 
 type Response []Result
 
 type Result struct {
+	// ID is the truck's stable ID, which fetches its details
+	ID           string          `json:"id"`
 	Name         string          `json:"name"`
+	City         string          `json:"city,omitempty"`
 	FacilityType string          `json:"facilityType"`
 	Fare         string          `json:"fare"`
+	Address      string          `json:"address,omitempty"`
 	Location     *ResultLocation `json:"location"`
+	Status       string          `json:"status,omitempty"`
+	Expires      *time.Time      `json:"expirationDate,omitempty"`
+	Hours        string          `json:"hours,omitempty"`
+	Open         *bool           `json:"open,omitempty"`
+	Certainty    float32         `json:"certainty,omitempty"`
+	Answer       *ResultAnswer   `json:"answer,omitempty"`
+	Scores       *ResultScores   `json:"scores,omitempty"`
 }
 
+// Meta describes how a recommendation was made
+type Meta struct {
+	// Certainty is the certainty threshold the results were found with
+	Certainty float32 `json:"certainty,omitempty"`
+
+	// DatasetVersion is the version of the dataset which was
+	// queried, empty if the store's dataset is not versioned
+	DatasetVersion string `json:"datasetVersion,omitempty"`
+
+	// Limit is the number of results which were asked
+	// for, or the size of the page
+	Limit int `json:"limit"`
+
+	// Total is the number of trucks the query matched, however many
+	// were returned. It is nil unless the results have been counted
+	Total *int `json:"total,omitempty"`
+
+	// filter is the filter the results were found with,
+	// which they are counted with too
+	filter Filter
+}
+
+func newMeta(ctx context.Context, certainty float32, limit int, filter Filter) *Meta {
+	return &Meta{
+		Certainty:      certainty,
+		DatasetVersion: DatasetVersion(ctx),
+		Limit:          limit,
+		filter:         filter,
+	}
+}
+
+// ResultAnswer is the span of the result's text which answers the
+// question, allowing clients to highlight the matched food item
+type ResultAnswer struct {
+	Text          string `json:"text,omitempty"`
+	Property      string `json:"property,omitempty"`
+	StartPosition int    `json:"startPosition"`
+	EndPosition   int    `json:"endPosition"`
+	HasAnswer     bool   `json:"hasAnswer"`
+}
+
+// ResultLocation is where the truck is. Source is dataset, or
+// state_plane if converted from the dataset's State Plane x/y
 type ResultLocation struct {
 	Latitude   float32 `json:"latitude,omitempty"`
 	Longitude  float32 `json:"longitude,omitempty"`
+	Source     string  `json:"source,omitempty"`
 	MetersAway float32 `json:"metersAway,omitempty"`
 	MilesAway  float32 `json:"milesAway,omitempty"`
 }
 
-type weaviateResponse struct {
-	Get struct {
-		FoodTruck []struct {
-			FacilityType string `json:"facility_type"`
-			FoodItems    string `json:"food_items"`
-			Location     struct {
-				Latitude  float32
-				Longitude float32
-			}
-			Name string
-		}
-	}
+// ResultScores breaks down how a result was ranked
+type ResultScores struct {
+	Relevance float32 `json:"relevance"`
+	Proximity float32 `json:"proximity"`
+	Final     float32 `json:"final"`
 }
 
-func checkWeaviateResponse(resp *models.GraphQLResponse, err error) error {
-	if err != nil {
-		return err
-	}
+// buildResponse cleans up the trucks returned by the
+// storage backend before they are sent to the user.
+// Trucks with known hours are flagged as open or closed at the
+// filter's time in their city, and trucks which could not be
+// located have no location, rather than one at 0,0
+func buildResponse(trucks []store.Truck, filter Filter) *Response {
+	at := filter.openAt()
 
-	if len(resp.Errors) != 0 {
-		return failure.CombineGraphQLErrors(resp.Errors)
-	}
+	resp := make(Response, len(trucks))
+	for i, t := range trucks {
+		resp[i] = Result{
+			ID:           t.ID,
+			Name:         t.Name,
+			City:         t.City,
+			FacilityType: t.FacilityType,
+			Fare:         t.FoodItems,
+			Address:      t.Address,
+			Status:       t.Status,
+			Hours:        t.DaysHours,
+			Certainty:    t.Certainty,
+		}
 
-	return nil
-}
+		if p := (geo.Point{Lat: t.Latitude, Lng: t.Longitude}); !p.IsZero() {
+			resp[i].Location = &ResultLocation{
+				Latitude:  t.Latitude,
+				Longitude: t.Longitude,
+				Source:    t.LocationSource,
+			}
+		}
 
-// because weaviate returns responses as `interface{}`, we have
-// to unmarshal+marshal to access the response fields. this
-// wouldn't be necessary if returning the weaviate payload
-// directly to the user, but we are going to clean it up a bit
-// first
-func buildResponse(gql *models.GraphQLResponse) (*Response, error) {
-	b, err := json.Marshal(gql.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal weaviate response")
-	}
+		if !t.ExpirationDate.IsZero() {
+			expires := t.ExpirationDate
+			resp[i].Expires = &expires
+		}
 
-	var wResp weaviateResponse
-	err = json.Unmarshal(b, &wResp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal weaviate response")
-	}
+		if len(t.OpenHours) > 0 {
+			open := t.IsOpen(cityTime(t.City, at))
+			resp[i].Open = &open
+		}
 
-	resp := make(Response, len(wResp.Get.FoodTruck))
-	for i := range wResp.Get.FoodTruck {
-		resp[i] = Result{
-			Name:         wResp.Get.FoodTruck[i].Name,
-			FacilityType: wResp.Get.FoodTruck[i].FacilityType,
-			Fare:         wResp.Get.FoodTruck[i].FoodItems,
-			Location: &ResultLocation{
-				Latitude:  wResp.Get.FoodTruck[i].Location.Latitude,
-				Longitude: wResp.Get.FoodTruck[i].Location.Longitude,
-			},
+		if t.Answer != nil {
+			resp[i].Answer = &ResultAnswer{
+				Text:          t.Answer.Result,
+				Property:      t.Answer.Property,
+				StartPosition: t.Answer.StartPosition,
+				EndPosition:   t.Answer.EndPosition,
+				HasAnswer:     t.Answer.HasAnswer,
+			}
 		}
 	}
 
-	return &resp, nil
+	return &resp
 }
@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"regexp"
+
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+const (
+	// DatasetClassName is the class holding the single pointer
+	// object which names the FoodTruck class being served
+	DatasetClassName = "FoodTruckDataset"
+
+	// DatasetID is the ID of the pointer object
+	DatasetID = "6c6f6e63-6865-4261-8000-000000000001"
+
+	PropActiveClass   = "active_class"
+	PropVersion       = "version"
+	PropObjects       = "objects"
+	PropActivatedAt   = "activated_at"
+	PropSchemaVersion = "schema_version"
+	PropWrites        = "writes"
+)
+
+// versionPattern matches the versions which are valid in a class name
+var versionPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ValidVersion reports whether the dataset version may be used in a
+// class name, which allows only letters, digits and underscores
+func ValidVersion(version string) bool {
+	return versionPattern.MatchString(version)
+}
+
+// VersionedClassName returns the name of the FoodTruck class
+// holding the dataset imported as the given version
+func VersionedClassName(version string) string {
+	return ClassName + "_v" + version
+}
+
+// NewVersioned returns a FoodTruck class for the given dataset version
+func NewVersioned(version string) *models.Class {
+	c := New()
+	c.Class = VersionedClassName(version)
+	return c
+}
+
+// NewDataset returns the class of the pointer object. It is not
+// vectorized, as it is only ever read by ID
+func NewDataset() *models.Class {
+	return &models.Class{
+		Class:       DatasetClassName,
+		Description: "Points at the FoodTruck class being served",
+		Vectorizer:  "none",
+		Properties: []*models.Property{
+			{
+				DataType:    []string{"string"},
+				Description: "Name of the FoodTruck class being served",
+				Name:        PropActiveClass,
+			},
+			{
+				DataType:    []string{"string"},
+				Description: "Version of the dataset being served",
+				Name:        PropVersion,
+			},
+			{
+				DataType:    []string{"int"},
+				Description: "Number of objects in the active class",
+				Name:        PropObjects,
+			},
+			{
+				DataType: []string{"date"},
+				Name:     PropActivatedAt,
+			},
+			{
+				DataType:    []string{"int"},
+				Description: "Schema version the active class was created with",
+				Name:        PropSchemaVersion,
+			},
+			{
+				DataType:    []string{"int"},
+				Description: "Number of admin writes to the active class since it was activated",
+				Name:        PropWrites,
+			},
+		},
+	}
+}
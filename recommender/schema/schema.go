@@ -1,18 +1,46 @@
 package schema
 
-import "github.com/semi-technologies/weaviate/entities/models"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+// Version identifies the FoodTruck class returned by New. It is bumped
+// whenever a property is added or changed, and recorded with each
+// activated dataset, so that a server refuses to query a dataset
+// imported under another version
+const Version = 1
 
 const (
 	ClassName = "FoodTruck"
 
+	PropCity                = "city"
 	PropName                = "name"
 	PropFacilityType        = "facility_type"
 	PropFoodItems           = "food_items"
 	PropLocation            = "location"
 	PropLocationLatitude    = "latitude"
 	PropLocationLongitude   = "longitude"
+	PropLocationSource      = "location_source"
+	PropAddress             = "address"
+	PropStatus              = "status"
+	PropExpirationDate      = "expiration_date"
+	PropSchedule            = "schedule"
+	PropDaysHours           = "days_hours"
+	PropOpenHours           = "open_hours"
 	PropAdditional          = "_additional"
+	PropAdditionalID        = "id"
 	PropAdditionalCertainty = "certainty"
+	PropAdditionalAnswer    = "answer"
+	PropAdditionalVector    = "vector"
+
+	PropAnswerResult        = "result"
+	PropAnswerProperty      = "property"
+	PropAnswerStartPosition = "startPosition"
+	PropAnswerEndPosition   = "endPosition"
+	PropAnswerHasAnswer     = "hasAnswer"
 )
 
 // New returns a Foodtruck Class instance
@@ -21,6 +49,11 @@ func New() *models.Class {
 		Class:       ClassName,
 		Description: "A mobile sustenance dispenser",
 		Properties: []*models.Property{
+			{
+				DataType:    []string{"string"},
+				Description: "Key of the city whose dataset the truck was imported from",
+				Name:        PropCity,
+			},
 			{
 				DataType: []string{"string"},
 				Name:     PropName,
@@ -37,6 +70,84 @@ func New() *models.Class {
 				DataType: []string{"geoCoordinates"},
 				Name:     PropLocation,
 			},
+			{
+				DataType:    []string{"string"},
+				Description: "Where the location came from: dataset, state_plane, or empty when the truck could not be located",
+				Name:        PropLocationSource,
+			},
+			{
+				DataType:    []string{"string"},
+				Description: "Street address of the truck's permitted location",
+				Name:        PropAddress,
+			},
+			{
+				DataType:    []string{"string"},
+				Description: "Permit status, e.g. APPROVED, REQUESTED, EXPIRED or SUSPEND",
+				Name:        PropStatus,
+			},
+			{
+				DataType: []string{"date"},
+				Name:     PropExpirationDate,
+			},
+			{
+				DataType:    []string{"string"},
+				Description: "Link to the permit's schedule document",
+				Name:        PropSchedule,
+			},
+			{
+				DataType:    []string{"string"},
+				Description: "Operating days and hours, e.g. Mo-Fr:10AM-3PM",
+				Name:        PropDaysHours,
+			},
+			{
+				DataType:    []string{"int[]"},
+				Description: "Hour-of-week slots during which the truck is open, where 0 is Sunday 12AM",
+				Name:        PropOpenHours,
+			},
 		},
 	}
 }
+
+// Diff compares an existing class against New, describing each
+// property which is missing from the existing class, or which has
+// a different data type. It returns nil if the class matches
+func Diff(existing *models.Class) []string {
+	props := make(map[string]*models.Property, len(existing.Properties))
+	for _, p := range existing.Properties {
+		props[p.Name] = p
+	}
+
+	var diffs []string
+	for _, want := range New().Properties {
+		got, ok := props[want.Name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("missing property %s", want.Name))
+			continue
+		}
+
+		if strings.Join(got.DataType, ",") != strings.Join(want.DataType, ",") {
+			diffs = append(diffs, fmt.Sprintf("property %s has type %v, want %v",
+				want.Name, got.DataType, want.DataType))
+		}
+	}
+
+	return diffs
+}
+
+// Missing returns the properties of want which the existing class
+// lacks, which may be added to it without reimporting
+func Missing(existing, want *models.Class) []*models.Property {
+	props := make(map[string]bool, len(existing.Properties))
+	for _, p := range existing.Properties {
+		props[p.Name] = true
+	}
+
+	var missing []*models.Property
+	for _, p := range want.Properties {
+		if !props[p.Name] {
+			missing = append(missing, p)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/graphql"
+)
+
+// DefaultPageSize is the number of objects a Cursor fetches
+// per request when no page size is given
+const DefaultPageSize = 100
+
+// Cursor pages through every truck of a FoodTruck class. Weaviate 1.13
+// has no cursor API, so pages are read by offset, which is stable as long
+// as the class isn't written to, as with any class which has been
+// activated. Offsets are bounded by Weaviate's QUERY_MAXIMUM_RESULTS
+type Cursor struct {
+	client   *weaviate.Client
+	class    string
+	pageSize int
+	vectors  bool
+
+	offset int
+	done   bool
+}
+
+// NewCursor returns a Cursor over the class, reading pageSize objects
+// at a time, along with their vectors if vectors is set
+func NewCursor(client *weaviate.Client, class string, pageSize int, vectors bool) *Cursor {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	return &Cursor{
+		client:   client,
+		class:    class,
+		pageSize: pageSize,
+		vectors:  vectors,
+	}
+}
+
+// Next returns the next page of trucks, or nil once every
+// truck of the class has been read
+func (c *Cursor) Next(ctx context.Context) ([]Truck, error) {
+	if c.done {
+		return nil, nil
+	}
+
+	var additional []graphql.Field
+	if c.vectors {
+		additional = append(additional, graphql.Field{Name: schema.PropAdditionalVector})
+	}
+
+	result, err := c.client.GraphQL().Get().
+		WithClassName(c.class).
+		WithFields(truckFields(additional...)...).
+		WithLimit(c.pageSize).
+		WithOffset(c.offset).
+		Do(ctx)
+
+	page, err := decodeTrucks(c.class, result, err)
+	if err != nil {
+		return nil, err
+	}
+
+	c.offset += len(page)
+	if len(page) < c.pageSize {
+		c.done = true
+	}
+	if len(page) == 0 {
+		return nil, nil
+	}
+
+	return page, nil
+}
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/fault"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+// datasetTTL is how long the active dataset is cached, which
+// bounds how long a cutover takes to reach the server
+const datasetTTL = 30 * time.Second
+
+// Dataset describes the FoodTruck class being served
+type Dataset struct {
+	// Class is the name of the active FoodTruck class, and Version
+	// the version it was imported as. Version is empty for the
+	// unversioned FoodTruck class of imports made before cutovers
+	Class   string
+	Version string
+
+	// Objects is the number of objects in the class when it was
+	// activated, and ActivatedAt the time of the cutover
+	Objects     int
+	ActivatedAt time.Time
+
+	// SchemaVersion is the schema.Version the class was created or last
+	// migrated with. It is zero for the unversioned FoodTruck class, and
+	// for datasets activated before schema versions were recorded
+	SchemaVersion int
+
+	// Writes counts the writes made to the class by the admin API since
+	// it was activated, so that changes made outside of an import can be
+	// noticed. It is always zero for the unversioned FoodTruck class
+	Writes int
+
+	// UpdatedAt is the pointer object's last update time, in Unix
+	// milliseconds. Counting a write updates it even when two servers
+	// count the same number of writes, so it is what tells an import
+	// that the class was written to
+	UpdatedAt int64
+}
+
+// Versioned is implemented by stores which serve a versioned dataset
+type Versioned interface {
+	// Version returns the version of the dataset being served,
+	// or an empty string if the dataset is not versioned
+	Version(ctx context.Context) (string, error)
+}
+
+// Revisioned is implemented by stores whose dataset
+// can be written to while it is being served
+type Revisioned interface {
+	// Revision returns the number of writes made to the
+	// dataset being served since it was activated
+	Revision(ctx context.Context) (int, error)
+}
+
+// ReadActiveDataset reads the pointer object naming the active
+// FoodTruck class. If there is none, the unversioned FoodTruck
+// class is active
+func ReadActiveDataset(ctx context.Context, client *weaviate.Client) (Dataset, error) {
+	objs, err := client.Data().ObjectsGetter().
+		WithID(schema.DatasetID).
+		Do(ctx)
+
+	var werr *fault.WeaviateClientError
+	if errors.As(err, &werr) && werr.StatusCode == http.StatusNotFound {
+		return Dataset{Class: schema.ClassName}, nil
+	}
+	if err != nil {
+		return Dataset{}, fmt.Errorf("failed to read active dataset: %s", failure.WeaviateError(err))
+	}
+
+	if len(objs) == 0 {
+		return Dataset{Class: schema.ClassName}, nil
+	}
+
+	b, err := json.Marshal(objs[0].Properties)
+	if err != nil {
+		return Dataset{}, fmt.Errorf("failed to marshal active dataset")
+	}
+
+	var props struct {
+		ActiveClass   string    `json:"active_class"`
+		Version       string    `json:"version"`
+		Objects       int       `json:"objects"`
+		ActivatedAt   time.Time `json:"activated_at"`
+		SchemaVersion int       `json:"schema_version"`
+		Writes        int       `json:"writes"`
+	}
+	if err := json.Unmarshal(b, &props); err != nil {
+		return Dataset{}, fmt.Errorf("failed to unmarshal active dataset")
+	}
+
+	if props.ActiveClass == "" {
+		return Dataset{}, fmt.Errorf("active dataset names no class")
+	}
+
+	return Dataset{
+		Class:         props.ActiveClass,
+		Version:       props.Version,
+		Objects:       props.Objects,
+		ActivatedAt:   props.ActivatedAt,
+		SchemaVersion: props.SchemaVersion,
+		Writes:        props.Writes,
+		UpdatedAt:     objs[0].LastUpdateTimeUnix,
+	}, nil
+}
+
+// countWrite adds a write to the pointer object's count of writes
+// to ds, which must be versioned, as only then is there a pointer.
+// Weaviate has no conditional update, so the count is read, then
+// replaced: servers writing at the same time may store the same count,
+// and the count is only exact with a single server making admin writes.
+// The pointer's update time still changes with every write counted
+func countWrite(ctx context.Context, client *weaviate.Client, ds Dataset) error {
+	err := client.Data().Updater().
+		WithMerge().
+		WithClassName(schema.DatasetClassName).
+		WithID(schema.DatasetID).
+		WithProperties(map[string]interface{}{schema.PropWrites: ds.Writes + 1}).
+		Do(ctx)
+
+	return failure.WeaviateError(err)
+}
+
+// CountObjects returns the number of objects stored in the class
+func CountObjects(ctx context.Context, client *weaviate.Client, class string) (int, error) {
+	result, err := client.GraphQL().Aggregate().
+		WithClassName(class).
+		WithFields(countFields()...).
+		Do(ctx)
+
+	return decodeCount(class, result, err)
+}
+
+// countFields are the fields of an Aggregate query counting objects
+func countFields() []graphql.Field {
+	return []graphql.Field{{Name: "meta", Fields: []graphql.Field{{Name: "count"}}}}
+}
+
+// decodeCount reads the object count from the result of
+// an Aggregate query with countFields
+func decodeCount(class string, result *models.GraphQLResponse, err error) (int, error) {
+	if err != nil {
+		return 0, failure.WeaviateError(err)
+	}
+	if len(result.Errors) > 0 {
+		return 0, fmt.Errorf("failed to count objects: %s", result.Errors[0].Message)
+	}
+
+	agg, _ := result.Data["Aggregate"].(map[string]interface{})
+	groups, _ := agg[class].([]interface{})
+	if len(groups) == 0 {
+		return 0, fmt.Errorf("failed to count objects: no result for %s", class)
+	}
+
+	group, _ := groups[0].(map[string]interface{})
+	meta, _ := group["meta"].(map[string]interface{})
+	count, ok := meta["count"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("failed to count objects: no count for %s", class)
+	}
+
+	return int(count), nil
+}
+
+// Activate points the dataset pointer at the class, which switches
+// every server over to it within their dataset cache TTL
+func Activate(ctx context.Context, client *weaviate.Client, class, version string, objects int) error {
+	props := map[string]interface{}{
+		schema.PropActiveClass:   class,
+		schema.PropVersion:       version,
+		schema.PropObjects:       objects,
+		schema.PropActivatedAt:   time.Now().UTC().Format(time.RFC3339),
+		schema.PropSchemaVersion: schema.Version,
+		schema.PropWrites:        0,
+	}
+
+	exists, err := client.Data().Checker().WithID(schema.DatasetID).Do(ctx)
+	if err != nil {
+		return failure.WeaviateError(err)
+	}
+
+	if exists {
+		err = client.Data().Updater().
+			WithClassName(schema.DatasetClassName).
+			WithID(schema.DatasetID).
+			WithProperties(props).
+			Do(ctx)
+	} else {
+		_, err = client.Data().Creator().
+			WithClassName(schema.DatasetClassName).
+			WithID(schema.DatasetID).
+			WithProperties(props).
+			Do(ctx)
+	}
+
+	return failure.WeaviateError(err)
+}
+
+// DropClass deletes the class and every object in it
+func DropClass(ctx context.Context, client *weaviate.Client, class string) error {
+	err := client.Schema().ClassDeleter().WithClassName(class).Do(ctx)
+	return failure.WeaviateError(err)
+}
+
+// DropUnactivated deletes a partially imported or restored version, which
+// was never activated. The import's ctx may already be cancelled, so the
+// drop gets its own, and a failure is logged as the caller is aborting
+func DropUnactivated(client *weaviate.Client, class string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := DropClass(ctx, client, class); err != nil {
+		log.Errorf("failed to drop unactivated %s: %s", class, err)
+	}
+}
+
+// CreateVersionedClass creates the class for a new dataset version, and
+// the pointer class if this is the first versioned dataset, returning
+// the name of the new class. It fails if the version already exists
+func CreateVersionedClass(ctx context.Context, client *weaviate.Client, version string) (string, error) {
+	class := schema.VersionedClassName(version)
+
+	existing, err := GetClass(ctx, client, class)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return "", fmt.Errorf("class %s already exists, choose another version", class)
+	}
+
+	if err := EnsureDatasetClass(ctx, client); err != nil {
+		return "", err
+	}
+
+	err = client.Schema().ClassCreator().WithClass(schema.NewVersioned(version)).Do(ctx)
+	if err != nil {
+		return "", failure.WeaviateError(err)
+	}
+
+	return class, nil
+}
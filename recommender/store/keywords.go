@@ -0,0 +1,111 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+)
+
+// stopWords are dropped from questions before keyword matching,
+// so that "where can i get a burger?" is reduced to "burger"
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "any": true, "are": true,
+	"best": true, "buy": true, "can": true, "do": true, "eat": true,
+	"find": true, "food": true, "for": true, "get": true, "good": true,
+	"has": true, "have": true, "i": true, "in": true, "is": true,
+	"like": true, "me": true, "my": true, "near": true, "of": true,
+	"on": true, "or": true, "place": true, "please": true, "sell": true,
+	"sells": true, "some": true, "the": true, "there": true, "to": true,
+	"truck": true, "want": true, "what": true, "where": true, "which": true,
+	"who": true, "with": true, "would": true, "you": true,
+}
+
+// tokenize splits text into lower-cased, singularized keywords
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var tokens []string
+	for _, f := range fields {
+		if stopWords[f] {
+			continue
+		}
+		tokens = append(tokens, singularize(f))
+	}
+
+	return tokens
+}
+
+func singularize(word string) string {
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"),
+		strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "xes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && len(word) > 3:
+		return strings.TrimSuffix(word, "s")
+	default:
+		return word
+	}
+}
+
+// matchKeywords returns the fraction of keywords found in tokens
+func matchKeywords(keywords, tokens []string) float32 {
+	present := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		present[t] = true
+	}
+
+	var matched int
+	for _, k := range keywords {
+		if present[k] {
+			matched++
+		}
+	}
+
+	return float32(matched) / float32(len(keywords))
+}
+
+// findAnswer picks out the first food item which contains one of the
+// keywords. Food items in the permit dataset are separated by colons
+func findAnswer(keywords []string, foodItems string) *Answer {
+	wanted := make(map[string]bool, len(keywords))
+	for _, k := range keywords {
+		wanted[k] = true
+	}
+
+	start := 0
+	for start <= len(foodItems) {
+		end := strings.IndexByte(foodItems[start:], ':')
+		if end < 0 {
+			end = len(foodItems)
+		} else {
+			end += start
+		}
+
+		for _, t := range tokenize(foodItems[start:end]) {
+			if !wanted[t] {
+				continue
+			}
+
+			item := foodItems[start:end]
+			trimmed := strings.TrimSpace(item)
+			offset := start + strings.Index(item, trimmed)
+
+			return &Answer{
+				Result:        trimmed,
+				Property:      schema.PropFoodItems,
+				StartPosition: offset,
+				EndPosition:   offset + len(trimmed),
+				HasAnswer:     true,
+			}
+		}
+
+		start = end + 1
+	}
+
+	return &Answer{HasAnswer: false}
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender/geo"
+)
+
+// gridCellSize is the size of each spatial index cell in degrees,
+// roughly 1km at the latitude of San Francisco
+const gridCellSize = 0.01
+
+// Memory is a Store which holds the whole permit dataset in memory.
+// It needs no running services, which makes it suitable for unit tests
+// and lightweight local development. Questions are answered with simple
+// keyword matching rather than a QnA model
+type Memory struct {
+	trucks []Truck
+	byID   map[string]int
+	grid   *geo.Grid
+
+	// gridTrucks holds the index of the truck of each point in the
+	// grid. Points are indexed in order of ID, which the grid breaks
+	// ties in distance by, as SortByDistance does
+	gridTrucks []int
+}
+
+// NewMemory returns a Memory store holding the given permit records
+func NewMemory(recs []permit.Record) *Memory {
+	trucks := make([]Truck, len(recs))
+	for i, rec := range recs {
+		trucks[i] = FromRecord(rec)
+	}
+
+	return newMemoryFromTrucks(trucks)
+}
+
+// LoadMemory reads the permit dataset at path, in any format, into a
+// Memory store. If m is nil, the default San Francisco column mapping
+// is used. Invalid rows are skipped with a warning
+func LoadMemory(path string, m *permit.Mapping) (*Memory, error) {
+	ds, err := permit.Read(path, permit.FormatAuto, m)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range ds.Rejects {
+		log.Warnf("skipping invalid permit row %d: %s", r.Row, r.Reason)
+	}
+	for _, r := range ds.Warnings {
+		log.Warnf("permit row %d: %s", r.Row, r.Reason)
+	}
+
+	return NewMemory(ds.Records), nil
+}
+
+func newMemoryFromTrucks(trucks []Truck) *Memory {
+	m := &Memory{
+		trucks: trucks,
+		byID:   make(map[string]int, len(trucks)),
+	}
+
+	m.gridTrucks = make([]int, len(trucks))
+	for i, t := range trucks {
+		m.byID[t.ID] = i
+		m.gridTrucks[i] = i
+	}
+	sort.Slice(m.gridTrucks, func(i, j int) bool {
+		return trucks[m.gridTrucks[i]].ID < trucks[m.gridTrucks[j]].ID
+	})
+
+	points := make([]geo.Point, len(trucks))
+	for i, ti := range m.gridTrucks {
+		points[i] = geo.Point{Lat: trucks[ti].Latitude, Lng: trucks[ti].Longitude}
+	}
+
+	m.grid = geo.NewGrid(points, gridCellSize)
+	return m
+}
+
+// WithinGeoRange implements Store. Trucks are returned nearest first
+func (m *Memory) WithinGeoRange(ctx context.Context, q GeoQuery) ([]Truck, error) {
+	hits := m.grid.Within(q.Center(), float64(q.MaxDistance), m.keep(q.Filter))
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+
+	return m.fromHits(hits), nil
+}
+
+// Nearest implements NearestFinder
+func (m *Memory) Nearest(ctx context.Context, q GeoQuery) ([]Truck, error) {
+	hits := m.grid.Nearest(q.Center(), q.Limit, float64(nearestRange(q)), m.keep(q.Filter))
+	return m.fromHits(hits), nil
+}
+
+// CountGeo implements Counter, counting within the range
+// searched by Nearest when the query has no maximum distance
+func (m *Memory) CountGeo(ctx context.Context, q GeoQuery) (int, error) {
+	return len(m.grid.Within(q.Center(), float64(nearestRange(q)), m.keep(q.Filter))), nil
+}
+
+// Ask implements Store. The certainty of each truck is the
+// fraction of the question's keywords found in its food items
+func (m *Memory) Ask(ctx context.Context, q AskQuery) ([]Truck, error) {
+	trucks := m.answer(q)
+	if len(trucks) > q.Limit {
+		trucks = trucks[:q.Limit]
+	}
+
+	return trucks, nil
+}
+
+// CountAsk implements Counter
+func (m *Memory) CountAsk(ctx context.Context, q AskQuery) (int, error) {
+	return len(m.answer(q)), nil
+}
+
+// answer returns every truck relevant to the question,
+// most relevant first
+func (m *Memory) answer(q AskQuery) []Truck {
+	keywords := tokenize(q.Question)
+	if len(keywords) == 0 {
+		return nil
+	}
+
+	candidates := m.trucks
+	if q.Within != nil {
+		candidates = m.fromHits(m.grid.Within(q.Within.Center(), float64(q.Within.MaxDistance), nil))
+	}
+
+	var trucks []Truck
+	for _, t := range candidates {
+		if !q.Filter.keep(&t) {
+			continue
+		}
+
+		certainty := matchKeywords(keywords, tokenize(t.FoodItems))
+		if certainty == 0 || certainty < q.Certainty {
+			continue
+		}
+
+		t.Certainty = certainty
+		t.Answer = findAnswer(keywords, t.FoodItems)
+		trucks = append(trucks, t)
+	}
+
+	sort.SliceStable(trucks, func(i, j int) bool {
+		return trucks[i].Certainty > trucks[j].Certainty
+	})
+
+	return trucks
+}
+
+// Get implements Store
+func (m *Memory) Get(ctx context.Context, id string) (*Truck, error) {
+	i, ok := m.byID[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	t := m.trucks[i]
+	return &t, nil
+}
+
+// ByName implements Store
+func (m *Memory) ByName(ctx context.Context, name string) ([]Truck, error) {
+	var trucks []Truck
+	for i := range m.trucks {
+		if m.trucks[i].hasName(name) {
+			trucks = append(trucks, m.trucks[i])
+		}
+	}
+	return trucks, nil
+}
+
+// All implements Store
+func (m *Memory) All(ctx context.Context) ([]Truck, error) {
+	trucks := make([]Truck, len(m.trucks))
+	copy(trucks, m.trucks)
+	return trucks, nil
+}
+
+// keep adapts the filter to the grid's index-based predicate
+func (m *Memory) keep(f Filter) func(int) bool {
+	return func(i int) bool {
+		return f.keep(&m.trucks[m.gridTrucks[i]])
+	}
+}
+
+func (m *Memory) fromHits(hits []geo.Hit) []Truck {
+	trucks := make([]Truck, len(hits))
+	for i, h := range hits {
+		trucks[i] = m.trucks[m.gridTrucks[h.Index]]
+	}
+	return trucks
+}
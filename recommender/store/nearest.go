@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/parkerduckworth/lonchera/recommender/geo"
+)
+
+const (
+	// nearestStartMeters is the first radius searched by Nearest
+	// for stores which cannot answer k-nearest queries directly
+	nearestStartMeters = 500
+
+	// nearestMaxMeters bounds k-nearest queries, and their
+	// counts, when the query does not provide a maximum distance
+	nearestMaxMeters = 80000
+
+	// nearestCandidates is the number of trucks requested from each
+	// geo range query, so that the closest ones can be picked out
+	nearestCandidates = 1000
+)
+
+// Nearest returns the q.Limit trucks closest to the query point,
+// nearest first. Stores implementing NearestFinder answer directly.
+// Otherwise the search radius is doubled until enough trucks are found
+func Nearest(ctx context.Context, s Store, q GeoQuery) ([]Truck, error) {
+	if nf, ok := s.(NearestFinder); ok {
+		return nf.Nearest(ctx, q)
+	}
+
+	maxMeters := nearestRange(q)
+	center := q.Center()
+	radius := float32(nearestStartMeters)
+
+	for {
+		if radius > maxMeters {
+			radius = maxMeters
+		}
+
+		trucks, err := s.WithinGeoRange(ctx, GeoQuery{
+			GeoRange: GeoRange{
+				Latitude:    q.Latitude,
+				Longitude:   q.Longitude,
+				MaxDistance: radius,
+			},
+			Filter: q.Filter,
+			Limit:  nearestCandidates,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(trucks) >= q.Limit || radius == maxMeters {
+			SortByDistance(center, trucks)
+			if len(trucks) > q.Limit {
+				trucks = trucks[:q.Limit]
+			}
+			return trucks, nil
+		}
+
+		radius *= 2
+	}
+}
+
+// nearestRange returns the distance searched by a k-nearest query:
+// its own maximum distance, or failing that nearestMaxMeters. Every
+// store bounds the search the same way, so that results don't depend
+// on which store answers
+func nearestRange(q GeoQuery) float32 {
+	if q.MaxDistance <= 0 {
+		return nearestMaxMeters
+	}
+	return q.MaxDistance
+}
+
+// SortByDistance orders trucks nearest first from center. Ties
+// are broken by ID so that the order is stable between queries
+func SortByDistance(center geo.Point, trucks []Truck) {
+	dist := make(map[string]float64, len(trucks))
+	for _, t := range trucks {
+		dist[t.ID] = geo.Distance(center, geo.Point{Lat: t.Latitude, Lng: t.Longitude})
+	}
+
+	sort.SliceStable(trucks, func(i, j int) bool {
+		di, dj := dist[trucks[i].ID], dist[trucks[j].ID]
+		if di == dj {
+			return trucks[i].ID < trucks[j].ID
+		}
+		return di < dj
+	})
+}
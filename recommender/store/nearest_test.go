@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+// rangeOnly hides the Memory store's NearestFinder, so that
+// Nearest falls back to geo range queries, as for Weaviate
+type rangeOnly struct {
+	Store
+}
+
+func TestNearestStoresAgree(t *testing.T) {
+	ctx := context.Background()
+
+	// trucks north of the center, a thousandth of a degree being about
+	// 111m. Two trucks are the same distance away, and are stored out
+	// of order of ID, and the last is about 110km away
+	m := newMemoryFromTrucks([]Truck{
+		{ID: "d", Name: "Near", Latitude: 37.7759, Longitude: -122.4194},
+		{ID: "c", Name: "Tied C", Latitude: 37.7789, Longitude: -122.4194},
+		{ID: "b", Name: "Tied B", Latitude: 37.7789, Longitude: -122.4194},
+		{ID: "a", Name: "Far", Latitude: 37.8049, Longitude: -122.4194},
+		{ID: "e", Name: "Out of Range", Latitude: 38.7749, Longitude: -122.4194},
+		{ID: "f", Name: "Unlocated"},
+	})
+
+	tests := []struct {
+		name        string
+		maxDistance float32
+		limit       int
+		want        []string
+		count       int
+	}{
+		{"unbounded", 0, 10, []string{"d", "b", "c", "a"}, 4},
+		{"cut between ties", 0, 2, []string{"d", "b"}, 4},
+		{"bounded", 1000, 10, []string{"d", "b", "c"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := GeoQuery{
+				GeoRange: GeoRange{Latitude: 37.7749, Longitude: -122.4194, MaxDistance: tt.maxDistance},
+				Limit:    tt.limit,
+			}
+
+			for _, s := range []Store{m, rangeOnly{m}} {
+				trucks, err := Nearest(ctx, s, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var got []string
+				for _, tr := range trucks {
+					got = append(got, tr.ID)
+				}
+				if len(got) != len(tt.want) {
+					t.Fatalf("%T: got %q, want %q", s, got, tt.want)
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Fatalf("%T: got %q, want %q", s, got, tt.want)
+					}
+				}
+			}
+
+			// the count is bounded as the query is, whatever its limit
+			count, err := m.CountGeo(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.count {
+				t.Errorf("count = %d, want %d", count, tt.count)
+			}
+		})
+	}
+}
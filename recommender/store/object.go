@@ -0,0 +1,51 @@
+package store
+
+import (
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+// NewObject returns the truck as an object of the class, under its
+// deterministic ID, along with its vector if it has one
+func NewObject(class string, t Truck) *models.Object {
+	props := map[string]interface{}{
+		schema.PropCity:         t.City,
+		schema.PropName:         t.Name,
+		schema.PropFacilityType: t.FacilityType,
+		schema.PropFoodItems:    t.FoodItems,
+		schema.PropAddress:      t.Address,
+		schema.PropStatus:       t.Status,
+		schema.PropSchedule:     t.Schedule,
+		schema.PropDaysHours:    t.DaysHours,
+	}
+
+	// an unlocated truck is stored without a location, rather
+	// than at 0,0, so that it never matches a geo query
+	if t.LocationSource != "" {
+		props[schema.PropLocation] = &models.GeoCoordinates{
+			Latitude:  &t.Latitude,
+			Longitude: &t.Longitude,
+		}
+		props[schema.PropLocationSource] = t.LocationSource
+	}
+
+	if !t.ExpirationDate.IsZero() {
+		props[schema.PropExpirationDate] = t.ExpirationDate.Format(time.RFC3339)
+	}
+
+	// most trucks don't list their hours, leave the slots unset
+	// rather than storing an empty array
+	if len(t.OpenHours) > 0 {
+		props[schema.PropOpenHours] = t.OpenHours
+	}
+
+	return &models.Object{
+		Class:      class,
+		ID:         strfmt.UUID(t.ID),
+		Properties: props,
+		Vector:     t.Vector,
+	}
+}
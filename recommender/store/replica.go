@@ -0,0 +1,281 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/log"
+)
+
+// defaultRefreshInterval is used when no refresh interval is configured
+const defaultRefreshInterval = 5 * time.Minute
+
+// Replica is a Store which answers geo range and k-nearest queries
+// from an in-memory spatial index of every truck in a primary store.
+// The index is refreshed periodically, and as soon as the primary's
+// dataset version or revision changes. Any other query, and geo queries made while
+// the index is stale, are passed through to the primary
+type Replica struct {
+	primary         Store
+	refreshInterval time.Duration
+	staleAfter      time.Duration
+	refreshNow      chan struct{}
+
+	mu          sync.RWMutex
+	snapshot    *Memory
+	version     string
+	revision    int
+	refreshedAt time.Time
+}
+
+// NewReplica returns a Replica of primary. The replica is
+// considered stale once staleAfter has passed since the
+// last successful refresh
+func NewReplica(primary Store, refreshInterval, staleAfter time.Duration) *Replica {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultRefreshInterval
+	}
+
+	if staleAfter < refreshInterval {
+		staleAfter = 3 * refreshInterval
+	}
+
+	return &Replica{
+		primary:         primary,
+		refreshInterval: refreshInterval,
+		staleAfter:      staleAfter,
+		refreshNow:      make(chan struct{}, 1),
+	}
+}
+
+// Start loads the replica, then keeps refreshing it in the
+// background every refresh interval until ctx is cancelled
+func (r *Replica) Start(ctx context.Context) {
+	if err := r.Refresh(ctx); err != nil {
+		log.Warnf("failed to load replica, serving from primary: %s", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-r.refreshNow:
+			}
+
+			if err := r.Refresh(ctx); err != nil {
+				log.Warnf("failed to refresh replica: %s", err)
+			}
+		}
+	}()
+}
+
+// Refresh replaces the replica with the current contents of the primary
+func (r *Replica) Refresh(ctx context.Context) error {
+	version, err := r.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	revision, err := r.Revision(ctx)
+	if err != nil {
+		return err
+	}
+
+	trucks, err := r.primary.All(ctx)
+	if err != nil {
+		return err
+	}
+
+	snapshot := newMemoryFromTrucks(trucks)
+
+	r.mu.Lock()
+	r.snapshot = snapshot
+	r.version = version
+	r.revision = revision
+	r.refreshedAt = time.Now()
+	r.mu.Unlock()
+
+	log.Debugf("replica refreshed with %d trucks", len(trucks))
+	return nil
+}
+
+// fresh returns the current snapshot, or nil if it is stale. A snapshot
+// of an old dataset version or revision is stale, and is refreshed
+// in the background. If the primary's version can't be read, the
+// primary is likely down, so the snapshot is served regardless
+func (r *Replica) fresh(ctx context.Context) *Memory {
+	r.mu.RLock()
+	snapshot, version, revision, refreshedAt := r.snapshot, r.version, r.revision, r.refreshedAt
+	r.mu.RUnlock()
+
+	if snapshot == nil || time.Since(refreshedAt) > r.staleAfter {
+		return nil
+	}
+
+	current, err := r.Version(ctx)
+	if err != nil {
+		log.Warnf("failed to check the dataset version, serving replica: %s", err)
+		return snapshot
+	}
+
+	currentRevision, err := r.Revision(ctx)
+	if err != nil {
+		log.Warnf("failed to check the dataset revision, serving replica: %s", err)
+		return snapshot
+	}
+
+	if current != version || currentRevision != revision {
+		select {
+		case r.refreshNow <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	return snapshot
+}
+
+// Version implements Versioned, returning the primary's version
+func (r *Replica) Version(ctx context.Context) (string, error) {
+	if v, ok := r.primary.(Versioned); ok {
+		return v.Version(ctx)
+	}
+	return "", nil
+}
+
+// Revision implements Revisioned, returning the primary's revision
+func (r *Replica) Revision(ctx context.Context) (int, error) {
+	if v, ok := r.primary.(Revisioned); ok {
+		return v.Revision(ctx)
+	}
+	return 0, nil
+}
+
+// CheckSchema implements SchemaChecker, checking the primary
+func (r *Replica) CheckSchema(ctx context.Context) error {
+	if c, ok := r.primary.(SchemaChecker); ok {
+		return c.CheckSchema(ctx)
+	}
+	return nil
+}
+
+// WithinGeoRange implements Store
+func (r *Replica) WithinGeoRange(ctx context.Context, q GeoQuery) ([]Truck, error) {
+	if m := r.fresh(ctx); m != nil {
+		return m.WithinGeoRange(ctx, q)
+	}
+
+	log.Debug("replica is stale, querying primary store")
+	return r.primary.WithinGeoRange(ctx, q)
+}
+
+// Nearest implements NearestFinder
+func (r *Replica) Nearest(ctx context.Context, q GeoQuery) ([]Truck, error) {
+	if m := r.fresh(ctx); m != nil {
+		return m.Nearest(ctx, q)
+	}
+
+	log.Debug("replica is stale, querying primary store")
+	return Nearest(ctx, r.primary, q)
+}
+
+// Ask implements Store
+func (r *Replica) Ask(ctx context.Context, q AskQuery) ([]Truck, error) {
+	return r.primary.Ask(ctx, q)
+}
+
+// CountGeo implements Counter
+func (r *Replica) CountGeo(ctx context.Context, q GeoQuery) (int, error) {
+	if m := r.fresh(ctx); m != nil {
+		return m.CountGeo(ctx, q)
+	}
+
+	c, ok := r.primary.(Counter)
+	if !ok {
+		return 0, errPrimaryCantCount
+	}
+
+	log.Debug("replica is stale, querying primary store")
+	return c.CountGeo(ctx, q)
+}
+
+// CountAsk implements Counter
+func (r *Replica) CountAsk(ctx context.Context, q AskQuery) (int, error) {
+	c, ok := r.primary.(Counter)
+	if !ok {
+		return 0, errPrimaryCantCount
+	}
+
+	return c.CountAsk(ctx, q)
+}
+
+var errPrimaryCantCount = errors.New("primary store can't count trucks")
+
+// Get implements Store
+func (r *Replica) Get(ctx context.Context, id string) (*Truck, error) {
+	return r.primary.Get(ctx, id)
+}
+
+// ByName implements Store
+func (r *Replica) ByName(ctx context.Context, name string) ([]Truck, error) {
+	if m := r.fresh(ctx); m != nil {
+		return m.ByName(ctx, name)
+	}
+
+	log.Debug("replica is stale, querying primary store")
+	return r.primary.ByName(ctx, name)
+}
+
+// All implements Store
+func (r *Replica) All(ctx context.Context) ([]Truck, error) {
+	if m := r.fresh(ctx); m != nil {
+		return m.All(ctx)
+	}
+
+	log.Debug("replica is stale, querying primary store")
+	return r.primary.All(ctx)
+}
+
+// Create implements Writer, writing to the primary
+func (r *Replica) Create(ctx context.Context, t Truck) error {
+	return r.write(func(w Writer) error { return w.Create(ctx, t) })
+}
+
+// Replace implements Writer, writing to the primary
+func (r *Replica) Replace(ctx context.Context, t Truck) error {
+	return r.write(func(w Writer) error { return w.Replace(ctx, t) })
+}
+
+// Delete implements Writer, writing to the primary
+func (r *Replica) Delete(ctx context.Context, id string) error {
+	return r.write(func(w Writer) error { return w.Delete(ctx, id) })
+}
+
+// write runs fn against the primary, then refreshes the index in
+// the background, so that geo queries see the change without
+// waiting for the refresh interval
+func (r *Replica) write(fn func(Writer) error) error {
+	w, ok := r.primary.(Writer)
+	if !ok {
+		return errPrimaryReadOnly
+	}
+
+	if err := fn(w); err != nil {
+		return err
+	}
+
+	select {
+	case r.refreshNow <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+var errPrimaryReadOnly = errors.New("primary store is read-only")
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+// SchemaChecker is implemented by stores whose dataset may have been
+// created with a schema other than the one the server was built with
+type SchemaChecker interface {
+	// CheckSchema returns an error if the dataset being
+	// served can't be queried with the current schema
+	CheckSchema(ctx context.Context) error
+}
+
+// CheckSchema returns an error if the active FoodTruck class can't be
+// queried with the current schema, because it was recorded under another
+// schema.Version, or because it doesn't match schema.New. It is not an
+// error for the unversioned class not to exist, as nothing was imported
+func CheckSchema(ctx context.Context, client *weaviate.Client) error {
+	active, err := ReadActiveDataset(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	class, err := GetClass(ctx, client, active.Class)
+	if err != nil {
+		return err
+	}
+
+	if class == nil {
+		if active.Version != "" {
+			return fmt.Errorf("active class %s does not exist", active.Class)
+		}
+		return nil
+	}
+
+	if active.SchemaVersion != 0 && active.SchemaVersion != schema.Version {
+		return fmt.Errorf("%s has schema version %d, want %d",
+			active.Class, active.SchemaVersion, schema.Version)
+	}
+
+	if diffs := schema.Diff(class); len(diffs) > 0 {
+		return fmt.Errorf("%s does not match the schema: %s",
+			active.Class, strings.Join(diffs, "; "))
+	}
+
+	return nil
+}
+
+// GetClass returns the named class, or nil if it doesn't exist
+func GetClass(ctx context.Context, client *weaviate.Client, name string) (*models.Class, error) {
+	dump, err := client.Schema().Getter().Do(ctx)
+	if err != nil {
+		return nil, failure.WeaviateError(err)
+	}
+
+	for _, class := range dump.Classes {
+		if class.Class == name {
+			return class, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// AddProperties adds each property of want which the existing
+// class lacks, returning the names of the properties added
+func AddProperties(ctx context.Context, client *weaviate.Client, existing, want *models.Class) ([]string, error) {
+	var added []string
+	for _, p := range schema.Missing(existing, want) {
+		err := client.Schema().PropertyCreator().
+			WithClassName(existing.Class).
+			WithProperty(p).
+			Do(ctx)
+
+		if err != nil {
+			return added, failure.WeaviateError(err)
+		}
+		added = append(added, p.Name)
+	}
+
+	return added, nil
+}
+
+// EnsureDatasetClass creates the class of the dataset pointer, or adds
+// the properties it lacks if it was created by an older version
+func EnsureDatasetClass(ctx context.Context, client *weaviate.Client) error {
+	class, err := GetClass(ctx, client, schema.DatasetClassName)
+	if err != nil {
+		return err
+	}
+
+	if class == nil {
+		err = client.Schema().ClassCreator().WithClass(schema.NewDataset()).Do(ctx)
+		return failure.WeaviateError(err)
+	}
+
+	_, err = AddProperties(ctx, client, class, schema.NewDataset())
+	return err
+}
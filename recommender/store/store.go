@@ -0,0 +1,280 @@
+// Package store provides the storage backends behind the recommender.
+// Each backend satisfies the Store interface, so the recommender can run
+// against a live Weaviate instance, or against an in-memory copy of the
+// permit dataset for offline testing and local development.
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender/geo"
+)
+
+// ErrNotFound is returned when a truck lookup by ID has no match
+var ErrNotFound = errors.New("food truck not found")
+
+// ErrExists is returned when a truck is created under an ID in use
+var ErrExists = errors.New("food truck already exists")
+
+// ErrDatasetChanged is returned when a truck is written while another
+// dataset is activated, so that the write may have gone to the old one
+var ErrDatasetChanged = errors.New("active dataset changed during the write")
+
+// Truck is a single food truck as held by a storage backend
+type Truck struct {
+	ID           string
+	City         string
+	Name         string
+	FacilityType string
+	FoodItems    string
+	Address      string
+
+	// Latitude and Longitude are zero for a truck which could not be
+	// located, and LocationSource tells where its location came from
+	Latitude       float32
+	Longitude      float32
+	LocationSource string
+
+	// Certainty is the relevance of the truck to an ask query,
+	// between 0 and 1. It is zero for any other kind of query
+	Certainty float32
+
+	// Status is the permit status, and ExpirationDate is
+	// zero when the permit has no expiration date
+	Status         string
+	ExpirationDate time.Time
+
+	// Schedule links to the permit's schedule document, and DaysHours
+	// and OpenHours describe when the truck is open. OpenHours holds
+	// hour-of-week slots, and is empty when the hours are unknown
+	Schedule  string
+	DaysHours string
+	OpenHours []int
+
+	// Answer is the part of the truck's properties which answers
+	// an ask query. It is nil for any other kind of query
+	Answer *Answer
+
+	// Vector is the truck's vector. It is only read by a Cursor
+	// listing vectors, and is nil for every query
+	Vector []float32
+}
+
+// Answer is the span of text extracted in response to a question
+type Answer struct {
+	Result        string
+	Property      string
+	StartPosition int
+	EndPosition   int
+	HasAnswer     bool
+}
+
+// Filter narrows the trucks considered by a query
+type Filter struct {
+	// City, if set, excludes trucks imported for any other city
+	City string
+
+	// OpenAt, if set, excludes trucks which are not known to be open at
+	// that time. The hour is read in the time zone of the truck's city
+	// in Zones, or failing that in the time zone of OpenAt itself
+	OpenAt *time.Time
+	Zones  map[string]*time.Location
+
+	// Statuses, if not empty, excludes trucks whose permit
+	// status is not in the list
+	Statuses []string
+
+	// ValidAt, if set, excludes trucks whose permit has expired by
+	// that time, or which have no expiration date at all
+	ValidAt *time.Time
+}
+
+// GeoRange is the area within MaxDistance meters of a point
+type GeoRange struct {
+	Latitude    float32
+	Longitude   float32
+	MaxDistance float32
+}
+
+// Center returns the point at the center of the range
+func (r GeoRange) Center() geo.Point {
+	return geo.Point{Lat: r.Latitude, Lng: r.Longitude}
+}
+
+// GeoQuery selects trucks inside a geo range
+type GeoQuery struct {
+	GeoRange
+	Filter
+	Limit int
+}
+
+// AskQuery selects trucks whose fare answers a free-form question
+// with at least the given certainty. If Within is set, only trucks
+// inside that geo range are considered
+type AskQuery struct {
+	Question  string
+	Certainty float32
+	Within    *GeoRange
+	Filter
+	Limit int
+}
+
+// Store is implemented by each recommender storage backend
+type Store interface {
+	// WithinGeoRange returns up to q.Limit trucks inside the geo range
+	WithinGeoRange(ctx context.Context, q GeoQuery) ([]Truck, error)
+
+	// Ask returns up to q.Limit trucks relevant to the question,
+	// most relevant first
+	Ask(ctx context.Context, q AskQuery) ([]Truck, error)
+
+	// Get returns the truck with the given ID, or ErrNotFound
+	Get(ctx context.Context, id string) (*Truck, error)
+
+	// ByName returns every truck whose applicant is named name,
+	// ignoring case, or none if the name is unknown
+	ByName(ctx context.Context, name string) ([]Truck, error)
+
+	// All returns every truck held by the store
+	All(ctx context.Context) ([]Truck, error)
+}
+
+// NearestFinder is implemented by stores which can answer k-nearest
+// queries directly, rather than through repeated geo range queries
+type NearestFinder interface {
+	// Nearest returns the q.Limit trucks closest to the query point,
+	// nearest first. If q.MaxDistance is positive, trucks further
+	// away than q.MaxDistance meters are ignored
+	Nearest(ctx context.Context, q GeoQuery) ([]Truck, error)
+}
+
+// Counter is implemented by stores which can count every truck a
+// query matches, without returning them
+type Counter interface {
+	// CountGeo returns the number of trucks inside the geo range,
+	// ignoring q.Limit. If q.MaxDistance is zero, it counts the trucks
+	// as far away as Nearest searches
+	CountGeo(ctx context.Context, q GeoQuery) (int, error)
+
+	// CountAsk returns the number of trucks relevant to the
+	// question with at least q.Certainty, ignoring q.Limit
+	CountAsk(ctx context.Context, q AskQuery) (int, error)
+}
+
+// Writer is implemented by stores whose trucks can be changed one at
+// a time, rather than only by importing a whole dataset
+type Writer interface {
+	// Create stores a new truck, or returns ErrExists
+	// if a truck is stored under its ID
+	Create(ctx context.Context, t Truck) error
+
+	// Replace overwrites every stored property of the
+	// truck with t's, or returns ErrNotFound
+	Replace(ctx context.Context, t Truck) error
+
+	// Delete removes the truck with the given ID, or returns ErrNotFound
+	Delete(ctx context.Context, id string) error
+}
+
+// FromRecord converts a permit dataset record into a Truck
+func FromRecord(rec permit.Record) Truck {
+	return Truck{
+		ID:             rec.ID(),
+		City:           rec.City,
+		Name:           rec.Applicant,
+		FacilityType:   rec.FacilityType,
+		FoodItems:      rec.FoodItems,
+		Address:        rec.Address,
+		Latitude:       rec.Latitude,
+		Longitude:      rec.Longitude,
+		LocationSource: rec.LocationSource,
+		Status:         rec.Status,
+		ExpirationDate: rec.ExpirationDate,
+		Schedule:       rec.Schedule,
+		DaysHours:      rec.DaysHours,
+		OpenHours:      rec.OpenHours,
+	}
+}
+
+// Equal reports whether two trucks hold the same stored
+// properties, ignoring query results such as Certainty
+func (t *Truck) Equal(o *Truck) bool {
+	if t.ID != o.ID ||
+		t.City != o.City ||
+		t.Name != o.Name ||
+		t.FacilityType != o.FacilityType ||
+		t.FoodItems != o.FoodItems ||
+		t.Latitude != o.Latitude ||
+		t.Longitude != o.Longitude ||
+		t.LocationSource != o.LocationSource ||
+		t.Address != o.Address ||
+		t.Status != o.Status ||
+		!t.ExpirationDate.Equal(o.ExpirationDate) ||
+		t.Schedule != o.Schedule ||
+		t.DaysHours != o.DaysHours ||
+		len(t.OpenHours) != len(o.OpenHours) {
+		return false
+	}
+
+	for i := range t.OpenHours {
+		if t.OpenHours[i] != o.OpenHours[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// localOpenAt returns OpenAt in the time zone of the city
+func (f Filter) localOpenAt(city string) time.Time {
+	if loc, ok := f.Zones[city]; ok {
+		return f.OpenAt.In(loc)
+	}
+	return *f.OpenAt
+}
+
+// IsOpen reports whether the truck is known to be open at t
+func (t *Truck) IsOpen(at time.Time) bool {
+	slot := permit.HourOfWeek(at)
+	for _, h := range t.OpenHours {
+		if h == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// hasName reports whether the truck's applicant is named name, ignoring case
+func (t *Truck) hasName(name string) bool {
+	return strings.EqualFold(strings.TrimSpace(t.Name), strings.TrimSpace(name))
+}
+
+// keep reports whether the truck passes the filter
+func (f Filter) keep(t *Truck) bool {
+	if f.City != "" && t.City != f.City {
+		return false
+	}
+
+	if f.OpenAt != nil && !t.IsOpen(f.localOpenAt(t.City)) {
+		return false
+	}
+
+	if f.ValidAt != nil && !t.ExpirationDate.After(*f.ValidAt) {
+		return false
+	}
+
+	if len(f.Statuses) == 0 {
+		return true
+	}
+
+	for _, s := range f.Statuses {
+		if t.Status == s {
+			return true
+		}
+	}
+	return false
+}
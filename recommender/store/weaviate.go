@@ -0,0 +1,678 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/log"
+	"github.com/parkerduckworth/lonchera/permit"
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/filters"
+	"github.com/semi-technologies/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+// Weaviate is a Store backed by the active FoodTruck class of a
+// running Weaviate instance. The active class is read from the
+// dataset pointer object, and cached briefly
+type Weaviate struct {
+	client *weaviate.Client
+
+	mu        sync.Mutex
+	dataset   Dataset
+	checkedAt time.Time
+
+	// writeMu serializes the writes made through the store, and
+	// ensured records that the dataset class holds the writes count
+	writeMu sync.Mutex
+	ensured bool
+}
+
+// NewWeaviate returns a Weaviate store for the instance described by config
+func NewWeaviate(config weaviate.Config) *Weaviate {
+	return &Weaviate{client: weaviate.New(config)}
+}
+
+type weaviateResponse struct {
+	Get map[string][]weaviateObject
+}
+
+type weaviateObject struct {
+	Name         string `json:"name"`
+	FacilityType string `json:"facility_type"`
+	FoodItems    string `json:"food_items"`
+	City         string `json:"city"`
+	Location     struct {
+		Latitude  float32 `json:"latitude"`
+		Longitude float32 `json:"longitude"`
+	} `json:"location"`
+	LocationSource string    `json:"location_source"`
+	Address        string    `json:"address"`
+	Status         string    `json:"status"`
+	Expiration     time.Time `json:"expiration_date"`
+	Schedule       string    `json:"schedule"`
+	DaysHours      string    `json:"days_hours"`
+	OpenHours      []int     `json:"open_hours"`
+	Additional     struct {
+		ID        string    `json:"id"`
+		Certainty float32   `json:"certainty"`
+		Vector    []float32 `json:"vector"`
+		Answer    *struct {
+			Result        string `json:"result"`
+			Property      string `json:"property"`
+			StartPosition int    `json:"startPosition"`
+			EndPosition   int    `json:"endPosition"`
+			HasAnswer     bool   `json:"hasAnswer"`
+		} `json:"answer"`
+	} `json:"_additional"`
+}
+
+// ActiveDataset returns the dataset being served
+func (w *Weaviate) ActiveDataset(ctx context.Context) (Dataset, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.dataset.Class != "" && time.Since(w.checkedAt) < datasetTTL {
+		return w.dataset, nil
+	}
+
+	ds, err := ReadActiveDataset(ctx, w.client)
+	if err != nil {
+		return Dataset{}, err
+	}
+
+	if w.dataset.Class != "" && ds.Class != w.dataset.Class {
+		log.Infof("serving dataset %s, was %s", ds.Class, w.dataset.Class)
+	}
+
+	w.dataset = ds
+	w.checkedAt = time.Now()
+	return ds, nil
+}
+
+// Version implements Versioned
+func (w *Weaviate) Version(ctx context.Context) (string, error) {
+	ds, err := w.ActiveDataset(ctx)
+	return ds.Version, err
+}
+
+// Revision implements Revisioned. The writes of other servers
+// are seen once the cached active dataset is read again
+func (w *Weaviate) Revision(ctx context.Context) (int, error) {
+	ds, err := w.ActiveDataset(ctx)
+	return ds.Writes, err
+}
+
+// CheckSchema implements SchemaChecker
+func (w *Weaviate) CheckSchema(ctx context.Context) error {
+	return CheckSchema(ctx, w.client)
+}
+
+// query runs q against the active class. If q fails, the active
+// dataset is read again, and q retried if there was a cutover in
+// the meantime, as the old class may have been dropped
+func (w *Weaviate) query(ctx context.Context, q func(class string) ([]Truck, error)) ([]Truck, error) {
+	ds, err := w.ActiveDataset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trucks, err := q(ds.Class)
+	if err == nil {
+		return trucks, nil
+	}
+
+	w.mu.Lock()
+	w.checkedAt = time.Time{}
+	w.mu.Unlock()
+
+	latest, lerr := w.ActiveDataset(ctx)
+	if lerr != nil || latest.Class == ds.Class {
+		return nil, err
+	}
+
+	return q(latest.Class)
+}
+
+// WithinGeoRange implements Store
+func (w *Weaviate) WithinGeoRange(ctx context.Context, q GeoQuery) ([]Truck, error) {
+	return w.query(ctx, func(class string) ([]Truck, error) {
+		result, err := w.client.GraphQL().Get().
+			WithClassName(class).
+			WithFields(truckFields()...).
+			WithWhere(whereFilter(geoRangeFilter(q.GeoRange), q.Filter)).
+			WithLimit(q.Limit).
+			Do(ctx)
+
+		return decodeTrucks(class, result, err)
+	})
+}
+
+// Ask implements Store
+func (w *Weaviate) Ask(ctx context.Context, q AskQuery) ([]Truck, error) {
+	return w.query(ctx, func(class string) ([]Truck, error) {
+		ask := w.client.GraphQL().AskArgBuilder().
+			WithQuestion(q.Question).
+			WithCertainty(q.Certainty)
+
+		get := w.client.GraphQL().Get().
+			WithClassName(class).
+			WithFields(truckFields(
+				graphql.Field{Name: schema.PropAdditionalCertainty},
+				graphql.Field{Name: schema.PropAdditionalAnswer, Fields: []graphql.Field{
+					{Name: schema.PropAnswerResult},
+					{Name: schema.PropAnswerProperty},
+					{Name: schema.PropAnswerStartPosition},
+					{Name: schema.PropAnswerEndPosition},
+					{Name: schema.PropAnswerHasAnswer},
+				}},
+			)...).
+			WithAsk(ask).
+			WithLimit(q.Limit)
+
+		var within *filters.WhereBuilder
+		if q.Within != nil {
+			within = geoRangeFilter(*q.Within)
+		}
+
+		if where := whereFilter(within, q.Filter); where != nil {
+			get = get.WithWhere(where)
+		}
+
+		result, err := get.Do(ctx)
+
+		return decodeTrucks(class, result, err)
+	})
+}
+
+// CountGeo implements Counter, counting within the range
+// searched by Nearest when the query has no maximum distance
+func (w *Weaviate) CountGeo(ctx context.Context, q GeoQuery) (int, error) {
+	r := q.GeoRange
+	r.MaxDistance = nearestRange(q)
+
+	return w.count(ctx, whereFilter(geoRangeFilter(r), q.Filter), nil)
+}
+
+// CountAsk implements Counter
+func (w *Weaviate) CountAsk(ctx context.Context, q AskQuery) (int, error) {
+	ask := w.client.GraphQL().AskArgBuilder().
+		WithQuestion(q.Question).
+		WithCertainty(q.Certainty)
+
+	var within *filters.WhereBuilder
+	if q.Within != nil {
+		within = geoRangeFilter(*q.Within)
+	}
+
+	return w.count(ctx, whereFilter(within, q.Filter), ask)
+}
+
+// count runs an Aggregate query counting the objects of the active
+// class matching where and ask, either of which may be nil
+func (w *Weaviate) count(ctx context.Context, where *filters.WhereBuilder, ask *graphql.AskArgumentBuilder) (int, error) {
+	var count int
+	_, err := w.query(ctx, func(class string) ([]Truck, error) {
+		agg := w.client.GraphQL().Aggregate().
+			WithClassName(class).
+			WithFields(countFields()...)
+
+		if where != nil {
+			agg = agg.WithWhere(where)
+		}
+		if ask != nil {
+			agg = agg.WithAsk(ask)
+		}
+
+		result, err := agg.Do(ctx)
+		count, err = decodeCount(class, result, err)
+		return nil, err
+	})
+
+	return count, err
+}
+
+// Get implements Store
+func (w *Weaviate) Get(ctx context.Context, id string) (*Truck, error) {
+	trucks, err := w.query(ctx, func(class string) ([]Truck, error) {
+		return w.getInClass(ctx, class, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(trucks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &trucks[0], nil
+}
+
+// getInClass looks the truck with the given ID up in the named class
+func (w *Weaviate) getInClass(ctx context.Context, class, id string) ([]Truck, error) {
+	result, err := w.client.GraphQL().Get().
+		WithClassName(class).
+		WithFields(truckFields()...).
+		WithWhere(filters.Where().
+			WithOperator(filters.Equal).
+			WithPath([]string{schema.PropAdditionalID}).
+			WithValueString(id)).
+		WithLimit(1).
+		Do(ctx)
+
+	return decodeTrucks(class, result, err)
+}
+
+// byNameLimit bounds the trucks returned by ByName. An applicant
+// holds a handful of permits, so it is never reached in practice
+const byNameLimit = 1000
+
+// graphqlEscaper escapes a string for a GraphQL string literal,
+// which the client builds without escaping values
+var graphqlEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// ByName implements Store. Names are tokenized into words by
+// Weaviate, so the Equal filter matches every truck whose name
+// holds the same words, and the exact matches are picked out
+func (w *Weaviate) ByName(ctx context.Context, name string) ([]Truck, error) {
+	where := filters.Where().
+		WithOperator(filters.Equal).
+		WithPath([]string{schema.PropName}).
+		WithValueString(graphqlEscaper.Replace(name))
+
+	candidates, err := w.query(ctx, func(class string) ([]Truck, error) {
+		result, err := w.client.GraphQL().Get().
+			WithClassName(class).
+			WithFields(truckFields()...).
+			WithWhere(where).
+			WithLimit(byNameLimit).
+			Do(ctx)
+
+		return decodeTrucks(class, result, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var trucks []Truck
+	for i := range candidates {
+		if candidates[i].hasName(name) {
+			trucks = append(trucks, candidates[i])
+		}
+	}
+	return trucks, nil
+}
+
+// Create implements Writer
+func (w *Weaviate) Create(ctx context.Context, t Truck) error {
+	return w.write(ctx, t.ID, false, func(class string) error {
+		return w.put(ctx, class, t)
+	})
+}
+
+// Replace implements Writer
+func (w *Weaviate) Replace(ctx context.Context, t Truck) error {
+	return w.write(ctx, t.ID, true, func(class string) error {
+		return w.put(ctx, class, t)
+	})
+}
+
+// Delete implements Writer. Like put, it goes through the
+// batch endpoint, which is scoped to the class
+func (w *Weaviate) Delete(ctx context.Context, id string) error {
+	return w.write(ctx, id, true, func(class string) error {
+		return w.delete(ctx, class, id)
+	})
+}
+
+// write makes a write to the truck with the given ID in the active
+// class, which is read uncached, once the truck is found to exist or
+// not as the write expects. Writes through the store are made one
+// at a time, and each is counted on the dataset pointer before it is
+// made, so that an import started before it refuses to activate rather
+// than drop it. If another dataset was activated while the write was
+// made, ErrDatasetChanged is returned
+func (w *Weaviate) write(ctx context.Context, id string, exists bool, f func(class string) error) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	ds, err := ReadActiveDataset(ctx, w.client)
+	if err != nil {
+		return err
+	}
+
+	trucks, err := w.getInClass(ctx, ds.Class, id)
+	if err != nil {
+		return err
+	}
+	if exists && len(trucks) == 0 {
+		return ErrNotFound
+	}
+	if !exists && len(trucks) > 0 {
+		return ErrExists
+	}
+
+	if ds.Version != "" {
+		if !w.ensured {
+			if err := EnsureDatasetClass(ctx, w.client); err != nil {
+				return err
+			}
+			w.ensured = true
+		}
+
+		if err := countWrite(ctx, w.client, ds); err != nil {
+			return fmt.Errorf("failed to count write to dataset %s: %s", ds.Version, err)
+		}
+	}
+
+	if err := f(ds.Class); err != nil {
+		return err
+	}
+
+	latest, err := ReadActiveDataset(ctx, w.client)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.dataset = latest
+	w.checkedAt = time.Now()
+	w.mu.Unlock()
+
+	if latest.Class != ds.Class {
+		return ErrDatasetChanged
+	}
+
+	return nil
+}
+
+// delete removes the truck with the given ID from the named class
+func (w *Weaviate) delete(ctx context.Context, class, id string) error {
+	resp, err := w.client.Batch().ObjectsBatchDeleter().
+		WithClassName(class).
+		WithWhere(filters.Where().
+			WithOperator(filters.Equal).
+			WithPath([]string{schema.PropAdditionalID}).
+			WithValueString(id)).
+		Do(ctx)
+
+	if err != nil {
+		return failure.WeaviateError(err)
+	}
+	if resp.Results != nil && resp.Results.Failed > 0 {
+		return fmt.Errorf("failed to delete truck %s", id)
+	}
+
+	return nil
+}
+
+// put writes the truck into the named class through the batch
+// endpoint, which upserts objects by ID within their class. The
+// object endpoints of Weaviate 1.13 look IDs up across every class,
+// and previous dataset versions hold trucks under the same IDs
+func (w *Weaviate) put(ctx context.Context, class string, t Truck) error {
+	resp, err := w.client.Batch().ObjectsBatcher().
+		WithObject(NewObject(class, t)).
+		Do(ctx)
+
+	if err != nil {
+		return failure.WeaviateError(err)
+	}
+
+	for _, r := range resp {
+		if r.Result != nil && r.Result.Errors != nil && len(r.Result.Errors.Error) > 0 {
+			return fmt.Errorf("failed to write truck %s: %s", t.ID, r.Result.Errors.Error[0].Message)
+		}
+	}
+
+	return nil
+}
+
+// All implements Store, paging through the whole active class
+func (w *Weaviate) All(ctx context.Context) ([]Truck, error) {
+	return w.query(ctx, func(class string) ([]Truck, error) {
+		return AllInClass(ctx, w.client, class)
+	})
+}
+
+// AllInClass pages through every truck in the named FoodTruck
+// class, which need not be the active one
+func AllInClass(ctx context.Context, client *weaviate.Client, class string) ([]Truck, error) {
+	var trucks []Truck
+	c := NewCursor(client, class, DefaultPageSize, false)
+	for {
+		page, err := c.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if page == nil {
+			return trucks, nil
+		}
+
+		trucks = append(trucks, page...)
+	}
+}
+
+// whereFilter combines the query's own where clause, which may be
+// nil, with the clauses required by the filter. It returns nil if
+// there is nothing to filter on
+func whereFilter(where *filters.WhereBuilder, f Filter) *filters.WhereBuilder {
+	var operands []*filters.WhereBuilder
+	if where != nil {
+		operands = append(operands, where)
+	}
+
+	if f.City != "" {
+		operands = append(operands, filters.Where().
+			WithOperator(filters.Equal).
+			WithPath([]string{schema.PropCity}).
+			WithValueString(graphqlEscaper.Replace(f.City)))
+	}
+
+	if f.OpenAt != nil {
+		operands = append(operands, openFilter(f))
+	}
+
+	if len(f.Statuses) > 0 {
+		statuses := make([]*filters.WhereBuilder, len(f.Statuses))
+		for i, s := range f.Statuses {
+			statuses[i] = filters.Where().
+				WithOperator(filters.Equal).
+				WithPath([]string{schema.PropStatus}).
+				WithValueString(graphqlEscaper.Replace(s))
+		}
+		operands = append(operands, combineFilters(filters.Or, statuses))
+	}
+
+	if f.ValidAt != nil {
+		operands = append(operands, filters.Where().
+			WithOperator(filters.GreaterThan).
+			WithPath([]string{schema.PropExpirationDate}).
+			WithValueDate(*f.ValidAt))
+	}
+
+	return combineFilters(filters.And, operands)
+}
+
+// openFilter matches the trucks open at the filter's OpenAt. Open
+// hours are in the local time of each truck's city, so the cities
+// whose hour of the week differs from OpenAt's own are each matched
+// against their hour, and left out of the match against OpenAt's
+func openFilter(f Filter) *filters.WhereBuilder {
+	// equality against an array property matches any element
+	openHour := func(hour int) *filters.WhereBuilder {
+		return filters.Where().
+			WithOperator(filters.Equal).
+			WithPath([]string{schema.PropOpenHours}).
+			WithValueInt(int64(hour))
+	}
+	city := func(operator filters.WhereOperator, city string) *filters.WhereBuilder {
+		return filters.Where().
+			WithOperator(operator).
+			WithPath([]string{schema.PropCity}).
+			WithValueString(graphqlEscaper.Replace(city))
+	}
+
+	if f.City != "" {
+		return openHour(permit.HourOfWeek(f.localOpenAt(f.City)))
+	}
+
+	hour := permit.HourOfWeek(*f.OpenAt)
+	byHour := make(map[int][]string)
+	for key := range f.Zones {
+		if h := permit.HourOfWeek(f.localOpenAt(key)); h != hour {
+			byHour[h] = append(byHour[h], key)
+		}
+	}
+	if len(byHour) == 0 {
+		return openHour(hour)
+	}
+
+	hours := make([]int, 0, len(byHour))
+	for h := range byHour {
+		hours = append(hours, h)
+	}
+	sort.Ints(hours)
+
+	rest := []*filters.WhereBuilder{openHour(hour)}
+	var matches []*filters.WhereBuilder
+	for _, h := range hours {
+		keys := byHour[h]
+		sort.Strings(keys)
+
+		cities := make([]*filters.WhereBuilder, len(keys))
+		for i, key := range keys {
+			cities[i] = city(filters.Equal, key)
+			rest = append(rest, city(filters.NotEqual, key))
+		}
+		matches = append(matches, combineFilters(filters.And, []*filters.WhereBuilder{
+			openHour(h), combineFilters(filters.Or, cities),
+		}))
+	}
+
+	return combineFilters(filters.Or, append(matches, combineFilters(filters.And, rest)))
+}
+
+// combineFilters joins the operands with the given operator,
+// unless there are fewer than two of them
+func combineFilters(operator filters.WhereOperator, operands []*filters.WhereBuilder) *filters.WhereBuilder {
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	default:
+		return filters.Where().
+			WithOperator(operator).
+			WithOperands(operands)
+	}
+}
+
+func geoRangeFilter(r GeoRange) *filters.WhereBuilder {
+	return filters.Where().
+		WithOperator(filters.WithinGeoRange).
+		WithPath([]string{schema.PropLocation}).
+		WithValueGeoRange(&filters.GeoCoordinatesParameter{
+			Latitude:    r.Latitude,
+			Longitude:   r.Longitude,
+			MaxDistance: r.MaxDistance,
+		})
+}
+
+// truckFields lists the properties decoded into a Truck, along
+// with any additional fields required by the query
+func truckFields(additional ...graphql.Field) []graphql.Field {
+	return []graphql.Field{
+		{Name: schema.PropCity},
+		{Name: schema.PropName},
+		{Name: schema.PropFacilityType},
+		{Name: schema.PropFoodItems},
+		{Name: schema.PropLocation, Fields: []graphql.Field{
+			{Name: schema.PropLocationLatitude},
+			{Name: schema.PropLocationLongitude},
+		}},
+		{Name: schema.PropLocationSource},
+		{Name: schema.PropAddress},
+		{Name: schema.PropStatus},
+		{Name: schema.PropExpirationDate},
+		{Name: schema.PropSchedule},
+		{Name: schema.PropDaysHours},
+		{Name: schema.PropOpenHours},
+		{Name: schema.PropAdditional, Fields: append([]graphql.Field{
+			{Name: schema.PropAdditionalID},
+		}, additional...)},
+	}
+}
+
+func checkWeaviateResponse(resp *models.GraphQLResponse, err error) error {
+	if err != nil {
+		return failure.WeaviateError(err)
+	}
+
+	if len(resp.Errors) != 0 {
+		return failure.CombineGraphQLErrors(resp.Errors)
+	}
+
+	return nil
+}
+
+// because weaviate returns responses as `interface{}`, we have
+// to unmarshal+marshal to access the response fields
+func decodeTrucks(class string, gql *models.GraphQLResponse, err error) ([]Truck, error) {
+	if err = checkWeaviateResponse(gql, err); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(gql.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal weaviate response")
+	}
+
+	var wResp weaviateResponse
+	err = json.Unmarshal(b, &wResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal weaviate response")
+	}
+
+	objs := wResp.Get[class]
+	trucks := make([]Truck, len(objs))
+	for i, obj := range objs {
+		trucks[i] = Truck{
+			ID:             obj.Additional.ID,
+			City:           obj.City,
+			Name:           obj.Name,
+			FacilityType:   obj.FacilityType,
+			FoodItems:      obj.FoodItems,
+			Latitude:       obj.Location.Latitude,
+			Longitude:      obj.Location.Longitude,
+			LocationSource: obj.LocationSource,
+			Address:        obj.Address,
+			Status:         obj.Status,
+			ExpirationDate: obj.Expiration,
+			Schedule:       obj.Schedule,
+			DaysHours:      obj.DaysHours,
+			OpenHours:      obj.OpenHours,
+			Certainty:      obj.Additional.Certainty,
+			Vector:         obj.Additional.Vector,
+		}
+
+		if a := obj.Additional.Answer; a != nil {
+			trucks[i].Answer = &Answer{
+				Result:        a.Result,
+				Property:      a.Property,
+				StartPosition: a.StartPosition,
+				EndPosition:   a.EndPosition,
+				HasAnswer:     a.HasAnswer,
+			}
+		}
+	}
+
+	return trucks, nil
+}
@@ -0,0 +1,93 @@
+package recommender
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/parkerduckworth/lonchera/failure"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+const (
+	ErrFailedToGetTruck  = "failed to get food truck"
+	ErrTruckNotFound     = "food truck not found"
+	ErrFailedToGetVendor = "failed to get vendor"
+	ErrVendorNotFound    = "vendor not found"
+)
+
+// Detail is every stored property of a truck
+type Detail struct {
+	Result
+
+	// Schedule links to the permit's schedule document, and
+	// OpenHours lists the hour-of-week slots the truck is open
+	Schedule  string `json:"schedule,omitempty"`
+	OpenHours []int  `json:"openHours,omitempty"`
+}
+
+// Vendor is an applicant, with every truck it holds a permit for
+type Vendor struct {
+	Name   string   `json:"name"`
+	Trucks []Detail `json:"trucks"`
+}
+
+func buildDetails(trucks []store.Truck) []Detail {
+	resp := buildResponse(trucks, Filter{})
+
+	details := make([]Detail, len(trucks))
+	for i, t := range trucks {
+		details[i] = Detail{
+			Result:    (*resp)[i],
+			Schedule:  t.Schedule,
+			OpenHours: t.OpenHours,
+		}
+	}
+	return details
+}
+
+// GetTruck returns the details of the truck with the given ID,
+// whatever the status of its permit
+func GetTruck(ctx context.Context, id string) (*Detail, *failure.Error) {
+	t, err := backend.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, failure.NewError(http.StatusNotFound, ErrTruckNotFound, err)
+	}
+	if err != nil {
+		return nil, failure.NewError(
+			http.StatusInternalServerError, ErrFailedToGetTruck, err)
+	}
+
+	return &buildDetails([]store.Truck{*t})[0], nil
+}
+
+// GetVendor returns the applicant named name, ignoring case, with
+// every truck it holds a permit for, whatever their status. Trucks
+// are ordered by city, then address
+func GetVendor(ctx context.Context, name string) (*Vendor, *failure.Error) {
+	trucks, err := backend.ByName(ctx, name)
+	if err != nil {
+		return nil, failure.NewError(
+			http.StatusInternalServerError, ErrFailedToGetVendor, err)
+	}
+	if len(trucks) == 0 {
+		return nil, failure.NewError(http.StatusNotFound, ErrVendorNotFound, nil)
+	}
+
+	sort.SliceStable(trucks, func(i, j int) bool {
+		a, b := trucks[i], trucks[j]
+		if a.City != b.City {
+			return a.City < b.City
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.ID < b.ID
+	})
+
+	return &Vendor{
+		Name:   trucks[0].Name,
+		Trucks: buildDetails(trucks),
+	}, nil
+}
@@ -0,0 +1,283 @@
+// Package snapshot writes the trucks of a FoodTruck class to CSV, JSONL
+// or GeoJSON, and reads JSONL snapshots back so they can be restored,
+// vectors included, into another Weaviate instance
+package snapshot
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+	"github.com/semi-technologies/weaviate/entities/models"
+)
+
+// Format is the encoding of a snapshot
+type Format string
+
+const (
+	// FormatJSONL holds one Weaviate object per line, with its ID,
+	// properties and vector. It is the only format which can be restored
+	FormatJSONL Format = "jsonl"
+
+	// FormatCSV holds one truck per row, under a header
+	// naming the FoodTruck properties
+	FormatCSV Format = "csv"
+
+	// FormatGeoJSON is a FeatureCollection with a Point feature per
+	// truck. Trucks which could not be located have no geometry
+	FormatGeoJSON Format = "geojson"
+)
+
+// Valid reports whether the format is known
+func (f Format) Valid() bool {
+	switch f {
+	case FormatJSONL, FormatCSV, FormatGeoJSON:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxLineSize bounds a line of a JSONL snapshot, which
+// holds a 768 dimension vector with room to spare
+const maxLineSize = 1 << 20
+
+// Writer writes trucks to a snapshot, one at a time
+type Writer interface {
+	Write(t store.Truck) error
+
+	// Close finishes the snapshot. It does not
+	// close the underlying io.Writer
+	Close() error
+}
+
+// NewWriter returns a Writer of the format to w. If vectors is set,
+// the CSV and GeoJSON formats include each truck's vector
+func NewWriter(w io.Writer, format Format, vectors bool) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w), vectors: vectors}, nil
+	case FormatGeoJSON:
+		return &geojsonWriter{w: w, vectors: vectors}, nil
+	default:
+		return nil, fmt.Errorf("unknown snapshot format %q", format)
+	}
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+// Write writes the truck as an object without a class,
+// which is given when the snapshot is restored
+func (w *jsonlWriter) Write(t store.Truck) error {
+	return w.enc.Encode(store.NewObject("", t))
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
+
+// csvColumns are the columns of a CSV snapshot, in order
+var csvColumns = []string{
+	"id",
+	schema.PropCity,
+	schema.PropName,
+	schema.PropFacilityType,
+	schema.PropFoodItems,
+	schema.PropAddress,
+	schema.PropLocationLatitude,
+	schema.PropLocationLongitude,
+	schema.PropLocationSource,
+	schema.PropStatus,
+	schema.PropExpirationDate,
+	schema.PropSchedule,
+	schema.PropDaysHours,
+	schema.PropOpenHours,
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	vectors bool
+	started bool
+}
+
+// header writes the header, unless it has been written already
+func (w *csvWriter) header() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	header := csvColumns
+	if w.vectors {
+		header = append(append([]string{}, csvColumns...), schema.PropAdditionalVector)
+	}
+	return w.w.Write(header)
+}
+
+func (w *csvWriter) Write(t store.Truck) error {
+	if err := w.header(); err != nil {
+		return err
+	}
+
+	// an unlocated truck has blank coordinates rather than 0,0
+	var lat, lng string
+	if t.LocationSource != "" {
+		lat = strconv.FormatFloat(float64(t.Latitude), 'f', -1, 32)
+		lng = strconv.FormatFloat(float64(t.Longitude), 'f', -1, 32)
+	}
+
+	var expiration string
+	if !t.ExpirationDate.IsZero() {
+		expiration = t.ExpirationDate.Format(time.RFC3339)
+	}
+
+	row := []string{
+		t.ID,
+		t.City,
+		t.Name,
+		t.FacilityType,
+		t.FoodItems,
+		t.Address,
+		lat,
+		lng,
+		t.LocationSource,
+		t.Status,
+		expiration,
+		t.Schedule,
+		t.DaysHours,
+		jsonArray(t.OpenHours),
+	}
+	if w.vectors {
+		row = append(row, jsonArray(t.Vector))
+	}
+
+	return w.w.Write(row)
+}
+
+func (w *csvWriter) Close() error {
+	// an empty snapshot still has its header
+	if err := w.header(); err != nil {
+		return err
+	}
+
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// jsonArray encodes a slice as a JSON array,
+// or as a blank cell if the slice is empty
+func jsonArray(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" || string(b) == "[]" {
+		return ""
+	}
+	return string(b)
+}
+
+type geojsonWriter struct {
+	w        io.Writer
+	vectors  bool
+	features int
+}
+
+type feature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Geometry   *point                 `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float32 `json:"coordinates"`
+}
+
+// Write writes the truck as a feature, streaming the collection
+// rather than holding every feature in memory
+func (w *geojsonWriter) Write(t store.Truck) error {
+	sep := ",\n"
+	if w.features == 0 {
+		sep = `{"type":"FeatureCollection","features":[` + "\n"
+	}
+
+	props := store.NewObject("", t).Properties.(map[string]interface{})
+	delete(props, schema.PropLocation)
+	if w.vectors && len(t.Vector) > 0 {
+		props[schema.PropAdditionalVector] = t.Vector
+	}
+
+	f := feature{Type: "Feature", ID: t.ID, Properties: props}
+	if t.LocationSource != "" {
+		f.Geometry = &point{Type: "Point", Coordinates: [2]float32{t.Longitude, t.Latitude}}
+	}
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w.w, sep); err != nil {
+		return err
+	}
+	_, err = w.w.Write(b)
+
+	w.features++
+	return err
+}
+
+func (w *geojsonWriter) Close() error {
+	end := "\n]}\n"
+	if w.features == 0 {
+		end = `{"type":"FeatureCollection","features":[]}` + "\n"
+	}
+
+	_, err := io.WriteString(w.w, end)
+	return err
+}
+
+// ReadJSONL reads every object of a JSONL snapshot. Objects are
+// returned without a class, and with their vectors if they were
+// exported with them
+func ReadJSONL(r io.Reader) ([]*models.Object, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var objs []*models.Object
+	seen := make(map[string]int)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var obj models.Object
+		if err := json.Unmarshal(scanner.Bytes(), &obj); err != nil {
+			return nil, fmt.Errorf("line %d: invalid object: %s", line, err)
+		}
+
+		if obj.ID == "" {
+			return nil, fmt.Errorf("line %d: object has no id", line)
+		}
+		if prev, ok := seen[obj.ID.String()]; ok {
+			return nil, fmt.Errorf("line %d: duplicate id %s, first seen on line %d", line, obj.ID, prev)
+		}
+		seen[obj.ID.String()] = line
+
+		obj.Class = ""
+		objs = append(objs, &obj)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %s", err)
+	}
+	return objs, nil
+}
@@ -0,0 +1,223 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parkerduckworth/lonchera/recommender/schema"
+	"github.com/parkerduckworth/lonchera/recommender/store"
+)
+
+// trucks are a located truck with its vector and hours,
+// and an unlocated truck with neither
+var trucks = []store.Truck{
+	{
+		ID:             "0b1c4a5e-6f3d-5a2b-9c8d-7e6f5a4b3c2d",
+		City:           "sf",
+		Name:           "Tacos, Etc",
+		FacilityType:   "Truck",
+		FoodItems:      "tacos: burritos",
+		Address:        "3750 18TH ST",
+		Latitude:       37.76202,
+		Longitude:      -122.42731,
+		LocationSource: "dataset",
+		Status:         "APPROVED",
+		ExpirationDate: time.Date(2030, 11, 15, 0, 0, 0, 0, time.UTC),
+		DaysHours:      "Mo-Fr:11AM-2PM",
+		OpenHours:      []int{35, 36},
+		Vector:         []float32{0.25, -0.5, 1},
+	},
+	{
+		ID:           "4d3c2b1a-0f9e-5d8c-8b7a-6f5e4d3c2b1a",
+		City:         "sf",
+		Name:         "Nowhere Burritos",
+		FacilityType: "Push Cart",
+		Status:       "REQUESTED",
+	},
+}
+
+// write writes every truck to a snapshot of the format
+func write(t *testing.T, format Format, vectors bool, trucks ...store.Truck) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format, vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range trucks {
+		if err := w.Write(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, "xml", false); err == nil {
+		t.Error("expected an unknown format to be refused")
+	}
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	out := write(t, FormatJSONL, false, trucks...)
+	if n := strings.Count(out, "\n"); n != len(trucks) {
+		t.Fatalf("got %d lines, expected one per truck", n)
+	}
+
+	objs, err := ReadJSONL(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != len(trucks) {
+		t.Fatalf("got %d objects, expected %d", len(objs), len(trucks))
+	}
+
+	for i, obj := range objs {
+		tr := trucks[i]
+		if obj.ID.String() != tr.ID {
+			t.Errorf("object %d: got id %s, expected %s", i, obj.ID, tr.ID)
+		}
+		if obj.Class != "" {
+			t.Errorf("object %d: got class %q, expected none", i, obj.Class)
+		}
+		if !reflect.DeepEqual([]float32(obj.Vector), tr.Vector) {
+			t.Errorf("object %d: got vector %v, expected %v", i, obj.Vector, tr.Vector)
+		}
+
+		props := obj.Properties.(map[string]interface{})
+		if props[schema.PropName] != tr.Name {
+			t.Errorf("object %d: got name %v, expected %q", i, props[schema.PropName], tr.Name)
+		}
+		if _, ok := props[schema.PropLocation]; ok != (tr.LocationSource != "") {
+			t.Errorf("object %d: got location %v, expected it only if located", i, props[schema.PropLocation])
+		}
+	}
+}
+
+func TestReadJSONLSkipsBlankLines(t *testing.T) {
+	out := write(t, FormatJSONL, false, trucks...)
+
+	objs, err := ReadJSONL(strings.NewReader("\n" + strings.Replace(out, "\n", "\n\n", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != len(trucks) {
+		t.Errorf("got %d objects, expected %d", len(objs), len(trucks))
+	}
+}
+
+func TestReadJSONLErrors(t *testing.T) {
+	line := strings.TrimSuffix(write(t, FormatJSONL, false, trucks[0]), "\n")
+
+	tests := []struct {
+		name, input, err string
+	}{
+		{"invalid object", line + "\n{\"id\":", "line 2: invalid object"},
+		{"no id", `{"properties":{}}`, "line 1: object has no id"},
+		{"duplicate id", line + "\n\n" + line, "line 3: duplicate id " + trucks[0].ID + ", first seen on line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadJSONL(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, expected %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	header := "id,city,name,facility_type,food_items,address,latitude,longitude," +
+		"location_source,status,expiration_date,schedule,days_hours,open_hours"
+
+	tests := []struct {
+		name    string
+		vectors bool
+		trucks  []store.Truck
+		want    []string
+	}{
+		{"empty", false, nil, []string{header}},
+		{"trucks", false, trucks, []string{
+			header,
+			trucks[0].ID + `,sf,"Tacos, Etc",Truck,tacos: burritos,3750 18TH ST,37.76202,-122.42731,` +
+				`dataset,APPROVED,2030-11-15T00:00:00Z,,Mo-Fr:11AM-2PM,"[35,36]"`,
+			trucks[1].ID + ",sf,Nowhere Burritos,Push Cart,,,,,,REQUESTED,,,,",
+		}},
+		{"vectors", true, trucks, []string{
+			header + ",vector",
+			trucks[0].ID + `,sf,"Tacos, Etc",Truck,tacos: burritos,3750 18TH ST,37.76202,-122.42731,` +
+				`dataset,APPROVED,2030-11-15T00:00:00Z,,Mo-Fr:11AM-2PM,"[35,36]","[0.25,-0.5,1]"`,
+			trucks[1].ID + ",sf,Nowhere Burritos,Push Cart,,,,,,REQUESTED,,,,,",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := write(t, FormatCSV, tt.vectors, tt.trucks...)
+			if want := strings.Join(tt.want, "\n") + "\n"; got != want {
+				t.Errorf("got\n%s\nexpected\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	type collection struct {
+		Type     string
+		Features []struct {
+			Type       string
+			ID         string
+			Geometry   *point
+			Properties map[string]interface{}
+		}
+	}
+
+	var empty collection
+	if err := json.Unmarshal([]byte(write(t, FormatGeoJSON, false)), &empty); err != nil {
+		t.Fatalf("empty snapshot is invalid: %s", err)
+	}
+	if empty.Type != "FeatureCollection" || len(empty.Features) != 0 {
+		t.Errorf("got %+v, expected an empty FeatureCollection", empty)
+	}
+
+	for _, vectors := range []bool{false, true} {
+		var got collection
+		if err := json.Unmarshal([]byte(write(t, FormatGeoJSON, vectors, trucks...)), &got); err != nil {
+			t.Fatalf("snapshot is invalid: %s", err)
+		}
+		if len(got.Features) != len(trucks) {
+			t.Fatalf("got %d features, expected %d", len(got.Features), len(trucks))
+		}
+
+		located, unlocated := got.Features[0], got.Features[1]
+		if located.ID != trucks[0].ID || unlocated.ID != trucks[1].ID {
+			t.Errorf("got ids %s and %s, expected the trucks' ids", located.ID, unlocated.ID)
+		}
+
+		// GeoJSON positions are longitude first
+		want := [2]float32{trucks[0].Longitude, trucks[0].Latitude}
+		if located.Geometry == nil || located.Geometry.Coordinates != want {
+			t.Errorf("got geometry %+v, expected a point at %v", located.Geometry, want)
+		}
+		if unlocated.Geometry != nil {
+			t.Errorf("got geometry %+v for an unlocated truck, expected none", unlocated.Geometry)
+		}
+		if _, ok := located.Properties[schema.PropLocation]; ok {
+			t.Error("expected the location to be the geometry, not a property")
+		}
+
+		_, ok := located.Properties[schema.PropAdditionalVector]
+		if ok != vectors {
+			t.Errorf("vectors %t: got vector property %t", vectors, ok)
+		}
+	}
+}